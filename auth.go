@@ -5,9 +5,11 @@ package vault_client //nolint:revive // Why: We're using - in the name
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
 )
 
 // AuthMethod is an authentication method that can be used
@@ -20,3 +22,34 @@ type AuthMethod interface {
 	// that need an underlying vault client to function.
 	Options(*Options)
 }
+
+// authLogin logs in to Vault using the login endpoint of an auth method
+// and returns the resulting auth, e.g. auth/kubernetes/login
+func (c *Client) authLogin(ctx context.Context, endpoint string, body interface{}) (*SecretAuth, error) {
+	var sec Secret
+	if err := c.doRequest(ctx, http.MethodPost, endpoint, body, &sec); err != nil {
+		return nil, err
+	}
+
+	if sec.Auth == nil {
+		return nil, errors.Errorf("no auth returned by %s", endpoint)
+	}
+	return sec.Auth, nil
+}
+
+// authToken returns the token of auth and when it expires, tokens without
+// a lease (e.g. root tokens) don't expire.
+func authToken(auth *SecretAuth) (cfg.SecretData, time.Time) {
+	if auth.LeaseDuration <= 0 {
+		return auth.ClientToken, time.Time{}
+	}
+	return auth.ClientToken, time.Now().Add(time.Duration(auth.LeaseDuration) * time.Second)
+}
+
+// authMount returns the provided auth method mount, or def if it's empty
+func authMount(mount, def string) string {
+	if mount == "" {
+		return def
+	}
+	return mount
+}
@@ -5,11 +5,13 @@ package vault_client //nolint:revive // Why: We're using - in the name
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"path"
 	"time"
 
 	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
 )
 
 // ApproleAuthMethod implements a AuthMethod backed by an approle
@@ -17,12 +19,19 @@ type ApproleAuthMethod struct {
 	c *Client
 
 	roleID   cfg.SecretData
-	secretID cfg.SecretData
+	secretID SecretIDProvider
 }
 
 // NewApproleAuthMethod returns a new ApproleAuthMethod based on the provided
 // roleID and secretID.
 func NewApproleAuthMethod(roleID, secretID cfg.SecretData) *ApproleAuthMethod {
+	return NewApproleAuthMethodWithProvider(roleID, staticSecretID(secretID))
+}
+
+// NewApproleAuthMethodWithProvider returns a new ApproleAuthMethod based on
+// the provided roleID, the secret_id is obtained from secretID on every
+// login. See SecretIDFile, WrappedSecretID and SecretIDFunc.
+func NewApproleAuthMethodWithProvider(roleID cfg.SecretData, secretID SecretIDProvider) *ApproleAuthMethod {
 	return &ApproleAuthMethod{
 		roleID:   roleID,
 		secretID: secretID,
@@ -30,12 +39,17 @@ func NewApproleAuthMethod(roleID, secretID cfg.SecretData) *ApproleAuthMethod {
 }
 
 func (a *ApproleAuthMethod) Options(o *Options) {
-	a.c = New(WithAddress(o.Host))
+	a.c = New(withInheritedOptions(o))
 }
 
 // GetToken returns a token for the current approle
 func (a *ApproleAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
-	resp, err := a.c.ApproleLogin(ctx, a.roleID, a.secretID)
+	secretID, err := a.secretID.SecretID(ctx, a.c)
+	if err != nil {
+		return "", time.Now(), errors.Wrap(err, "failed to get approle secret_id")
+	}
+
+	resp, err := a.c.ApproleLogin(ctx, a.roleID, secretID)
 	if err != nil {
 		return "", time.Now(), err
 	}
@@ -64,11 +78,13 @@ type ApproleLoginResponse struct {
 // ApproleLogin creates a new VAULT_TOKEN using the provided approle credentials
 func (c *Client) ApproleLogin(ctx context.Context, roleID, secretID cfg.SecretData) (*ApproleLoginResponse, error) {
 	var resp ApproleLoginResponse
-	err := c.doRequest(ctx, http.MethodPost, "auth/approle/login", map[string]string{
+	if err := c.doRequest(ctx, http.MethodPost, "auth/approle/login", map[string]string{
 		"role_id":   string(roleID),
 		"secret_id": string(secretID),
-	}, &resp)
-	return &resp, err
+	}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
 }
 
 // CreateApproleOptions are options to provide to CreateApprole, docs:
@@ -77,11 +93,21 @@ type CreateApproleOptions struct {
 	// Name is the name of the approle to create
 	Name string `json:"-"`
 
-	TokenTTL      string   `json:"token_ttl,omitempty"`
-	TokenMaxTTL   string   `json:"token_max_ttl,omitempty"`
-	TokenPolicies []string `json:"token_policies,omitempty"`
-	Period        int      `json:"period,omitempty"`
-	BindSecretID  bool     `json:"bind_secret_id,omitempty"`
+	TokenTTL             string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL          string   `json:"token_max_ttl,omitempty"`
+	TokenExplicitMaxTTL  string   `json:"token_explicit_max_ttl,omitempty"`
+	TokenPolicies        []string `json:"token_policies,omitempty"`
+	TokenBoundCIDRs      []string `json:"token_bound_cidrs,omitempty"`
+	TokenNoDefaultPolicy bool     `json:"token_no_default_policy,omitempty"`
+	TokenNumUses         int      `json:"token_num_uses,omitempty"`
+	TokenPeriod          string   `json:"token_period,omitempty"`
+	TokenType            string   `json:"token_type,omitempty"`
+	Period               int      `json:"period,omitempty"`
+	BindSecretID         bool     `json:"bind_secret_id,omitempty"`
+	SecretIDTTL          string   `json:"secret_id_ttl,omitempty"`
+	SecretIDNumUses      int      `json:"secret_id_num_uses,omitempty"`
+	SecretIDBoundCIDRs   []string `json:"secret_id_bound_cidrs,omitempty"`
+	LocalSecretIDs       bool     `json:"local_secret_ids,omitempty"`
 }
 
 // CreateApprole creates a new approle in Vault
@@ -89,6 +115,53 @@ func (c *Client) CreateApprole(ctx context.Context, opts *CreateApproleOptions)
 	return c.doRequest(ctx, http.MethodPost, path.Join("auth/approle/role", opts.Name), opts, nil)
 }
 
+// Approle is an approle returned by GetApprole, durations are in seconds
+type Approle struct {
+	TokenTTL             int      `json:"token_ttl"`
+	TokenMaxTTL          int      `json:"token_max_ttl"`
+	TokenExplicitMaxTTL  int      `json:"token_explicit_max_ttl"`
+	TokenPolicies        []string `json:"token_policies"`
+	TokenBoundCIDRs      []string `json:"token_bound_cidrs"`
+	TokenNoDefaultPolicy bool     `json:"token_no_default_policy"`
+	TokenNumUses         int      `json:"token_num_uses"`
+	TokenPeriod          int      `json:"token_period"`
+	TokenType            string   `json:"token_type"`
+	BindSecretID         bool     `json:"bind_secret_id"`
+	SecretIDTTL          int      `json:"secret_id_ttl"`
+	SecretIDNumUses      int      `json:"secret_id_num_uses"`
+	SecretIDBoundCIDRs   []string `json:"secret_id_bound_cidrs"`
+	LocalSecretIDs       bool     `json:"local_secret_ids"`
+}
+
+// GetApprole returns an approle
+func (c *Client) GetApprole(ctx context.Context, name string) (*Approle, error) {
+	var resp struct {
+		Data Approle `json:"data"`
+	}
+
+	if err := c.doRequest(ctx, http.MethodGet, path.Join("auth/approle/role", name), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// ListApproles returns the names of all approles
+func (c *Client) ListApproles(ctx context.Context) ([]string, error) {
+	return c.listApprole(ctx, "auth/approle/role")
+}
+
+// DeleteApprole deletes an approle
+func (c *Client) DeleteApprole(ctx context.Context, name string) error {
+	return c.doRequest(ctx, http.MethodDelete, path.Join("auth/approle/role", name), nil, nil)
+}
+
+// SetApproleRoleID sets a custom role-id for a given approle
+func (c *Client) SetApproleRoleID(ctx context.Context, name string, roleID cfg.SecretData) error {
+	return c.doRequest(ctx, http.MethodPost, path.Join("auth/approle/role", name, "role-id"), map[string]string{
+		"role_id": string(roleID),
+	}, nil)
+}
+
 // GetApproleRoleID returns the role-id for a given approle
 func (c *Client) GetApproleRoleID(ctx context.Context, name string) (cfg.SecretData, error) {
 	var resp struct {
@@ -127,3 +200,175 @@ func (c *Client) CreateApproleSecretID(ctx context.Context, name string) (*Creat
 
 	return &resp.Data, nil
 }
+
+// CreateApproleSecretIDOptions are options to provide to
+// CreateApproleSecretIDWithOptions, docs:
+// https://developer.hashicorp.com/vault/api-docs/auth/approle#generate-new-secret-id
+type CreateApproleSecretIDOptions struct {
+	// SecretID is a custom secret_id to create, if empty Vault generates
+	// the secret_id.
+	SecretID cfg.SecretData
+
+	// Metadata is metadata tied to the secret_id, it's added to the
+	// metadata of tokens created with the secret_id.
+	Metadata map[string]string
+
+	// CIDRList are the CIDR blocks the secret_id can be used from
+	CIDRList []string
+
+	// TokenBoundCIDRs are the CIDR blocks tokens created with the secret_id
+	// can be used from
+	TokenBoundCIDRs []string
+
+	// NumUses is how often the secret_id can be used, 0 is unlimited
+	NumUses int
+
+	// TTL is how long the secret_id is valid, e.g. 1h
+	TTL string
+}
+
+// CreateApproleSecretIDWithOptions creates a new secret_id, or the custom
+// secret_id in opts, for a given approle
+func (c *Client) CreateApproleSecretIDWithOptions(ctx context.Context, name string,
+	opts *CreateApproleSecretIDOptions) (*CreateApproleSecretIDResponse, error) {
+	body := map[string]interface{}{}
+	if len(opts.Metadata) != 0 {
+		// Vault expects the metadata to be a JSON encoded string
+		metadata, err := json.Marshal(opts.Metadata)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode secret_id metadata")
+		}
+		body["metadata"] = string(metadata)
+	}
+
+	if len(opts.CIDRList) != 0 {
+		body["cidr_list"] = opts.CIDRList
+	}
+
+	if len(opts.TokenBoundCIDRs) != 0 {
+		body["token_bound_cidrs"] = opts.TokenBoundCIDRs
+	}
+
+	if opts.NumUses != 0 {
+		body["num_uses"] = opts.NumUses
+	}
+
+	if opts.TTL != "" {
+		body["ttl"] = opts.TTL
+	}
+
+	endpoint := "secret-id"
+	if opts.SecretID != "" {
+		endpoint = "custom-secret-id"
+		body["secret_id"] = string(opts.SecretID)
+	}
+
+	resp := struct {
+		Data CreateApproleSecretIDResponse `json:"data"`
+	}{}
+
+	err := c.doRequest(ctx, http.MethodPost, path.Join("auth/approle/role", name, endpoint), body, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp.Data, nil
+}
+
+// ApproleSecretID is a secret_id returned by LookupApproleSecretID, the
+// secret_id itself is never returned.
+type ApproleSecretID struct {
+	SecretIDAccessor string            `json:"secret_id_accessor"`
+	Metadata         map[string]string `json:"metadata"`
+	CIDRList         []string          `json:"cidr_list"`
+	TokenBoundCIDRs  []string          `json:"token_bound_cidrs"`
+	SecretIDNumUses  int               `json:"secret_id_num_uses"`
+	SecretIDTTL      int               `json:"secret_id_ttl"`
+	CreationTime     time.Time         `json:"creation_time"`
+	ExpirationTime   time.Time         `json:"expiration_time"`
+	LastUpdatedTime  time.Time         `json:"last_updated_time"`
+}
+
+// LookupApproleSecretID returns information about a secret_id of a given
+// approle. If the secret_id doesn't exist an error matching IsNotFound is
+// returned.
+func (c *Client) LookupApproleSecretID(ctx context.Context, name string, secretID cfg.SecretData) (*ApproleSecretID, error) {
+	return c.lookupApproleSecretID(ctx, path.Join("auth/approle/role", name, "secret-id/lookup"), map[string]string{
+		"secret_id": string(secretID),
+	})
+}
+
+// LookupApproleSecretIDAccessor returns information about the secret_id of
+// a given approle with the provided accessor. If the secret_id doesn't exist
+// an error matching IsNotFound is returned.
+func (c *Client) LookupApproleSecretIDAccessor(ctx context.Context, name, accessor string) (*ApproleSecretID, error) {
+	return c.lookupApproleSecretID(ctx, path.Join("auth/approle/role", name, "secret-id-accessor/lookup"), map[string]string{
+		"secret_id_accessor": accessor,
+	})
+}
+
+// lookupApproleSecretID looks up a secret_id using the provided endpoint
+func (c *Client) lookupApproleSecretID(ctx context.Context, endpoint string, body interface{}) (*ApproleSecretID, error) {
+	var resp *struct {
+		Data ApproleSecretID `json:"data"`
+	}
+
+	if err := c.doRequest(ctx, http.MethodPost, endpoint, body, &resp); err != nil {
+		return nil, err
+	}
+
+	// Vault returns no content for secret_ids that don't exist
+	if resp == nil {
+		return nil, &ResponseError{
+			StatusCode: http.StatusNotFound,
+			Method:     http.MethodPost,
+			Endpoint:   endpoint,
+			Errors:     []string{"secret_id not found"},
+		}
+	}
+	return &resp.Data, nil
+}
+
+// DestroyApproleSecretID destroys a secret_id of a given approle
+func (c *Client) DestroyApproleSecretID(ctx context.Context, name string, secretID cfg.SecretData) error {
+	return c.doRequest(ctx, http.MethodPost, path.Join("auth/approle/role", name, "secret-id/destroy"), map[string]string{
+		"secret_id": string(secretID),
+	}, nil)
+}
+
+// DestroyApproleSecretIDAccessor destroys the secret_id of a given approle
+// with the provided accessor
+func (c *Client) DestroyApproleSecretIDAccessor(ctx context.Context, name, accessor string) error {
+	return c.doRequest(ctx, http.MethodPost, path.Join("auth/approle/role", name, "secret-id-accessor/destroy"),
+		map[string]string{"secret_id_accessor": accessor}, nil)
+}
+
+// ListApproleSecretIDAccessors returns the accessors of all secret_ids of a
+// given approle
+func (c *Client) ListApproleSecretIDAccessors(ctx context.Context, name string) ([]string, error) {
+	return c.listApprole(ctx, path.Join("auth/approle/role", name, "secret-id"))
+}
+
+// TidyApproleSecretIDs cleans up expired secret_ids and their accessors,
+// the tidy runs in the background in Vault.
+func (c *Client) TidyApproleSecretIDs(ctx context.Context) error {
+	return c.doRequest(ctx, http.MethodPost, "auth/approle/tidy/secret-id", nil, nil)
+}
+
+// listApprole lists the keys at an approle endpoint, no keys are returned
+// if there are none.
+func (c *Client) listApprole(ctx context.Context, endpoint string) ([]string, error) {
+	var resp struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	if err := c.doRequest(ctx, "LIST", endpoint, nil, &resp); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return resp.Data.Keys, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements the sources of approle secret_ids
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
+)
+
+// SecretIDProvider provides the secret_id used by an ApproleAuthMethod,
+// it's called on every login.
+type SecretIDProvider interface {
+	// SecretID returns the secret_id to login with, c is a client for the
+	// Vault instance being logged in to.
+	SecretID(ctx context.Context, c *Client) (cfg.SecretData, error)
+}
+
+// staticSecretID is a SecretIDProvider for a fixed secret_id
+type staticSecretID cfg.SecretData
+
+// SecretID implements SecretIDProvider
+func (s staticSecretID) SecretID(context.Context, *Client) (cfg.SecretData, error) {
+	return cfg.SecretData(s), nil
+}
+
+// SecretIDFunc is a SecretIDProvider backed by a function, e.g. to fetch
+// the secret_id from a rotating source.
+type SecretIDFunc func(ctx context.Context) (cfg.SecretData, error)
+
+// SecretID implements SecretIDProvider
+func (f SecretIDFunc) SecretID(ctx context.Context, _ *Client) (cfg.SecretData, error) {
+	return f(ctx)
+}
+
+// SecretIDFile is a SecretIDProvider that reads the secret_id from a file on
+// every login, so a secret_id rotated on disk is picked up.
+type SecretIDFile struct {
+	path   string
+	remove bool
+
+	mu     sync.Mutex
+	cached cfg.SecretData
+}
+
+// NewSecretIDFile returns a SecretIDFile reading the secret_id from path. If
+// remove is true the file is removed after reading it, like Vault Agent's
+// remove_secret_id_file_after_reading, and the secret_id is kept in memory
+// until a new file is written.
+func NewSecretIDFile(path string, remove bool) *SecretIDFile {
+	return &SecretIDFile{path: path, remove: remove}
+}
+
+// SecretID implements SecretIDProvider
+func (f *SecretIDFile) SecretID(context.Context, *Client) (cfg.SecretData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) && f.cached != "" {
+		return f.cached, nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read secret_id at '%s'", f.path)
+	}
+
+	secretID := cfg.SecretData(strings.TrimSpace(string(b)))
+	if secretID == "" {
+		return "", errors.Errorf("secret_id file '%s' is empty", f.path)
+	}
+
+	if f.remove {
+		if err := os.Remove(f.path); err != nil {
+			return "", errors.Wrapf(err, "failed to remove secret_id file '%s'", f.path)
+		}
+		f.cached = secretID
+	}
+
+	return secretID, nil
+}
+
+// WrappedSecretID is a SecretIDProvider for a response-wrapped secret_id,
+// e.g. created with Client.Wrap and CreateApproleSecretID. The wrapping
+// token is unwrapped on first use and the secret_id is kept in memory.
+type WrappedSecretID struct {
+	token cfg.SecretData
+
+	mu       sync.Mutex
+	secretID cfg.SecretData
+}
+
+// NewWrappedSecretID returns a WrappedSecretID for the provided wrapping
+// token
+func NewWrappedSecretID(token cfg.SecretData) *WrappedSecretID {
+	return &WrappedSecretID{token: token}
+}
+
+// SecretID implements SecretIDProvider
+func (w *WrappedSecretID) SecretID(ctx context.Context, c *Client) (cfg.SecretData, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.secretID != "" {
+		return w.secretID, nil
+	}
+
+	var resp struct {
+		Data CreateApproleSecretIDResponse `json:"data"`
+	}
+	if err := c.UnwrapWithWrappingToken(ctx, w.token, &resp); err != nil {
+		return "", errors.Wrap(err, "failed to unwrap secret_id")
+	}
+
+	if resp.Data.SecretID == "" {
+		return "", errors.New("wrapped response doesn't contain a secret_id")
+	}
+
+	w.secretID = resp.Data.SecretID
+	return w.secretID, nil
+}
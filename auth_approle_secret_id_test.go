@@ -0,0 +1,131 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
+)
+
+// createTestApprole creates an approle named after the test and returns its
+// role_id
+func createTestApprole(ctx context.Context, t *testing.T, vc *Client) cfg.SecretData {
+	t.Helper()
+
+	if err := vc.CreateAuthMethod(ctx, &CreateAuthMethodOptions{Type: "approle"}); err != nil {
+		t.Fatalf("Failed to create pre-req auth method: CreateAuthMethod() = %v", err)
+	}
+
+	if err := vc.CreateApprole(ctx, &CreateApproleOptions{Name: t.Name(), TokenPolicies: []string{"default"}}); err != nil {
+		t.Fatalf("Failed to create pre-req approle: CreateApprole() = %v", err)
+	}
+
+	roleID, err := vc.GetApproleRoleID(ctx, t.Name())
+	if err != nil {
+		t.Fatalf("Failed to get pre-req approle role-id: GetApproleRoleID() = %v", err)
+	}
+	return roleID
+}
+
+func TestSecretIDProviders(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+	role := t.Name()
+	roleID := createTestApprole(ctx, t, vc)
+
+	newSecretID := func() cfg.SecretData {
+		resp, err := vc.CreateApproleSecretID(ctx, role)
+		if err != nil {
+			t.Fatalf("Failed to create pre-req secret_id: CreateApproleSecretID() = %v", err)
+		}
+		return resp.SecretID
+	}
+
+	login := func(provider SecretIDProvider) error {
+		am := NewApproleAuthMethodWithProvider(roleID, provider)
+		am.Options(vc.opts)
+		_, _, err := am.GetToken(ctx)
+		return err
+	}
+
+	t.Run("should re-read the secret_id file and remove it after reading", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "secret-id")
+		if err := os.WriteFile(file, []byte(newSecretID()+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		provider := NewSecretIDFile(file, true)
+		if err := login(provider); err != nil {
+			t.Errorf("GetToken() = %v", err)
+			return
+		}
+
+		if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected secret_id file to be removed, got %v", err)
+		}
+
+		// the secret_id is kept in memory once the file is removed
+		if err := login(provider); err != nil {
+			t.Errorf("GetToken(): expected the cached secret_id to be used, got %v", err)
+			return
+		}
+
+		// a rotated secret_id is picked up
+		if err := os.WriteFile(file, []byte("invalid"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := login(provider); err == nil {
+			t.Error("GetToken(): expected the rotated secret_id to be used")
+		}
+	})
+
+	t.Run("should unwrap the secret_id on first use", func(t *testing.T) {
+		wrapInfo, err := vc.Wrap(time.Minute, func(wc *Client) error {
+			_, err := wc.CreateApproleSecretID(ctx, role)
+			return err
+		})
+		if err != nil {
+			t.Errorf("Failed to wrap secret_id: Wrap() = %v", err)
+			return
+		}
+
+		provider := NewWrappedSecretID(wrapInfo.Token)
+		for i := 0; i < 2; i++ {
+			if err := login(provider); err != nil {
+				t.Errorf("GetToken() = %v", err)
+				return
+			}
+		}
+
+		if err := login(NewWrappedSecretID(wrapInfo.Token)); err == nil {
+			t.Error("GetToken(): expected an already unwrapped token to fail")
+		}
+	})
+
+	t.Run("should call the secret_id func on every login", func(t *testing.T) {
+		secretID, calls := newSecretID(), 0
+		provider := SecretIDFunc(func(context.Context) (cfg.SecretData, error) {
+			calls++
+			return secretID, nil
+		})
+
+		for i := 0; i < 2; i++ {
+			if err := login(provider); err != nil {
+				t.Errorf("GetToken() = %v", err)
+				return
+			}
+		}
+
+		if calls != 2 {
+			t.Errorf("expected the secret_id func to be called on every login, got %d calls", calls)
+		}
+	})
+}
@@ -3,6 +3,9 @@ package vault_client //nolint:revive // Why: We're using - in the name
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -64,3 +67,181 @@ func TestClient_ApproleLogin(t *testing.T) {
 		t.Error("LookupToken(): expected resp.ID to have a value")
 	}
 }
+
+func TestApproleAuthMethod_InheritsNamespace(t *testing.T) {
+	var loginNamespace string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/approle/login" {
+			loginNamespace = r.Header.Get("X-Vault-Namespace")
+		}
+		w.Write([]byte(`{"auth":{"client_token":"token","lease_duration":3600}}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	c := New(WithAddress(srv.URL), WithNamespace("team"), WithApproleAuth("role-id", "secret-id"))
+	if _, err := c.LookupCurrentToken(context.Background()); err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	if loginNamespace != "team" {
+		t.Errorf("expected approle login to use namespace 'team', got %q", loginNamespace)
+	}
+}
+
+func TestClient_ApproleAdministration(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+	name := t.Name()
+
+	if err := vc.CreateAuthMethod(ctx, &CreateAuthMethodOptions{Type: "approle"}); err != nil {
+		t.Errorf("Failed to create pre-req auth method: CreateAuthMethod() = %v", err)
+		return
+	}
+
+	if err := vc.CreateApprole(ctx, &CreateApproleOptions{
+		Name:               name,
+		BindSecretID:       true,
+		SecretIDNumUses:    5,
+		SecretIDTTL:        "1h",
+		SecretIDBoundCIDRs: []string{"127.0.0.1/32"},
+		TokenType:          "batch",
+	}); err != nil {
+		t.Errorf("CreateApprole() = %v", err)
+		return
+	}
+
+	role, err := vc.GetApprole(ctx, name)
+	if err != nil {
+		t.Errorf("GetApprole() = %v", err)
+		return
+	}
+
+	if role.SecretIDNumUses != 5 || role.SecretIDTTL != 3600 || role.TokenType != "batch" ||
+		len(role.SecretIDBoundCIDRs) != 1 || role.SecretIDBoundCIDRs[0] != "127.0.0.1/32" {
+		t.Errorf("GetApprole(): unexpected role %+v", role)
+	}
+
+	roles, err := vc.ListApproles(ctx)
+	if err != nil {
+		t.Errorf("ListApproles() = %v", err)
+		return
+	}
+
+	// Vault lowercases approle names
+	if len(roles) != 1 || roles[0] != strings.ToLower(name) {
+		t.Errorf("ListApproles(): expected [%s], got %v", name, roles)
+	}
+
+	if err := vc.SetApproleRoleID(ctx, name, "custom-role-id"); err != nil {
+		t.Errorf("SetApproleRoleID() = %v", err)
+		return
+	}
+
+	if roleID, err := vc.GetApproleRoleID(ctx, name); err != nil || roleID != "custom-role-id" {
+		t.Errorf("GetApproleRoleID(): expected custom-role-id, got %q, %v", roleID, err)
+	}
+
+	if err := vc.DeleteApprole(ctx, name); err != nil {
+		t.Errorf("DeleteApprole() = %v", err)
+		return
+	}
+
+	if _, err := vc.GetApprole(ctx, name); err == nil {
+		t.Error("GetApprole(): expected an error for a deleted approle")
+	}
+
+	if roles, err := vc.ListApproles(ctx); err != nil || len(roles) != 0 {
+		t.Errorf("ListApproles(): expected no approles, got %v, %v", roles, err)
+	}
+}
+
+func TestClient_ApproleSecretIDs(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+	name := t.Name()
+
+	if err := vc.CreateAuthMethod(ctx, &CreateAuthMethodOptions{Type: "approle"}); err != nil {
+		t.Errorf("Failed to create pre-req auth method: CreateAuthMethod() = %v", err)
+		return
+	}
+
+	if err := vc.CreateApprole(ctx, &CreateApproleOptions{Name: name, BindSecretID: true}); err != nil {
+		t.Errorf("Failed to create pre-req approle: CreateApprole() = %v", err)
+		return
+	}
+
+	custom, err := vc.CreateApproleSecretIDWithOptions(ctx, name, &CreateApproleSecretIDOptions{
+		SecretID: "custom-secret-id",
+		Metadata: map[string]string{"team": "platform"},
+		CIDRList: []string{"127.0.0.1/32"},
+		NumUses:  3,
+		TTL:      "1h",
+	})
+	if err != nil {
+		t.Errorf("CreateApproleSecretIDWithOptions() = %v", err)
+		return
+	}
+
+	if custom.SecretID != "custom-secret-id" || custom.SecretIDAccessor == "" {
+		t.Errorf("CreateApproleSecretIDWithOptions(): unexpected response %+v", custom)
+	}
+
+	info, err := vc.LookupApproleSecretID(ctx, name, custom.SecretID)
+	if err != nil {
+		t.Errorf("LookupApproleSecretID() = %v", err)
+		return
+	}
+
+	if info.SecretIDAccessor != custom.SecretIDAccessor || info.Metadata["team"] != "platform" ||
+		info.SecretIDNumUses != 3 || info.SecretIDTTL != 3600 || len(info.CIDRList) != 1 || info.CreationTime.IsZero() {
+		t.Errorf("LookupApproleSecretID(): unexpected secret_id %+v", info)
+	}
+
+	generated, err := vc.CreateApproleSecretIDWithOptions(ctx, name, &CreateApproleSecretIDOptions{})
+	if err != nil {
+		t.Errorf("CreateApproleSecretIDWithOptions() = %v", err)
+		return
+	}
+
+	accessors, err := vc.ListApproleSecretIDAccessors(ctx, name)
+	if err != nil {
+		t.Errorf("ListApproleSecretIDAccessors() = %v", err)
+		return
+	}
+
+	if len(accessors) != 2 {
+		t.Errorf("ListApproleSecretIDAccessors(): expected 2 accessors, got %v", accessors)
+	}
+
+	if _, err := vc.LookupApproleSecretIDAccessor(ctx, name, generated.SecretIDAccessor); err != nil {
+		t.Errorf("LookupApproleSecretIDAccessor() = %v", err)
+		return
+	}
+
+	if err := vc.DestroyApproleSecretID(ctx, name, custom.SecretID); err != nil {
+		t.Errorf("DestroyApproleSecretID() = %v", err)
+		return
+	}
+
+	if _, err := vc.LookupApproleSecretID(ctx, name, custom.SecretID); !IsNotFound(err) {
+		t.Errorf("LookupApproleSecretID(): expected not found for a destroyed secret_id, got %v", err)
+	}
+
+	if err := vc.DestroyApproleSecretIDAccessor(ctx, name, generated.SecretIDAccessor); err != nil {
+		t.Errorf("DestroyApproleSecretIDAccessor() = %v", err)
+		return
+	}
+
+	if accessors, err := vc.ListApproleSecretIDAccessors(ctx, name); err != nil || len(accessors) != 0 {
+		t.Errorf("ListApproleSecretIDAccessors(): expected no accessors, got %v, %v", accessors, err)
+	}
+
+	if err := vc.TidyApproleSecretIDs(ctx); err != nil {
+		t.Errorf("TidyApproleSecretIDs() = %v", err)
+	}
+}
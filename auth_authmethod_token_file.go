@@ -20,31 +20,42 @@ var defaultFileName = ".vault-token"
 // TokenFileAuthMethod implements a AuthMethod backed by a static authentication token
 type TokenFileAuthMethod struct {
 	tokenFilePath string
+
+	// err is returned by GetToken if the token file couldn't be located
+	err error
+
+	// opts are the options of the client using this auth method, used
+	// to lookup the token against the same Vault instance.
+	opts *Options
 }
 
 // NewTokenFileAuthMethod returns a new TokenAuthMethod that uses a file as the backing for
-// a TokenAuthMethod. If the file is not provided the default vault token file is used.
+// a TokenAuthMethod. If the file is not provided the default vault token file is used, if
+// that can't be located (e.g. there's no home directory) GetToken returns an error.
 //
 // Note: The token is re-read from the file on expiration but currently there is mothing in place
 // to actually renew the token for you.
 func NewTokenFileAuthMethod(file *string) AuthMethod {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		// Return an empty token auth method
-		return &TokenAuthMethod{""}
-	}
-
 	// use the default path
 	if file == nil {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return &TokenFileAuthMethod{err: errors.Wrap(err, "failed to locate vault token file")}
+		}
+
 		joinedPath := filepath.Join(homeDir, defaultFileName)
 		file = &joinedPath
 	}
 
-	return &TokenFileAuthMethod{*file}
+	return &TokenFileAuthMethod{tokenFilePath: *file}
 }
 
 // GetToken returns the static token while implementing AuthMethod.GetToken()
 func (a *TokenFileAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	if a.err != nil {
+		return "", time.Time{}, a.err
+	}
+
 	// read the token into memory
 	b, err := os.ReadFile(a.tokenFilePath)
 	if err != nil {
@@ -52,21 +63,40 @@ func (a *TokenFileAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, tim
 	}
 
 	token := cfg.SecretData(strings.TrimSpace(string(b)))
+	expiresAt, err := staticTokenExpiry(ctx, a.opts, token)
+	if err != nil {
+		return "", time.Time{}, errors.Wrapf(err, "failed to lookup vault token at '%s'", a.tokenFilePath)
+	}
+	return token, expiresAt, nil
+}
+
+// staticTokenExpiry looks up token, which was provided by the user, and
+// returns when it expires so it's re-read then. Tokens that aren't
+// renewable never expire. If the token can't be looked up, e.g. because
+// Vault rejected it as invalid, an error is returned so that a
+// ChainAuthMethod moves on to its next AuthMethod.
+func staticTokenExpiry(ctx context.Context, opts *Options, token cfg.SecretData) (time.Time, error) {
+	// without the client's options, e.g. when used by NewTransport, there's
+	// no Vault server to look the token up with
+	if opts == nil {
+		return time.Time{}, nil
+	}
 
 	// use an intermediate client to lookup the token and return when it expires
-	intermediateClient := New(WithTokenAuth(token))
+	intermediateClient := New(withInheritedOptions(opts), WithTokenAuth(token))
 	tokenInfo, err := intermediateClient.LookupCurrentToken(ctx)
 	if err != nil {
-		// if we failed to lookup the token just disable renewal
-		tokenInfo = &LookupTokenResponse{
-			ExpireTime: time.Time{},
-		}
-	} else if !tokenInfo.Renewable {
-		// if not renewable, don't return an expiration time
-		tokenInfo.ExpireTime = time.Time{}
+		return time.Time{}, err
 	}
 
-	return token, tokenInfo.ExpireTime, nil
+	if !tokenInfo.Renewable {
+		return time.Time{}, nil
+	}
+	return tokenInfo.ExpireTime, nil
 }
 
-func (*TokenFileAuthMethod) Options(*Options) {}
+// Options stores the client's options so the token can be looked up
+// using the same address and namespace.
+func (a *TokenFileAuthMethod) Options(o *Options) {
+	a.opts = o
+}
@@ -87,3 +87,11 @@ func TestNewTokenFileAuthMethodReturnsDefault(t *testing.T) {
 		return
 	}
 }
+
+func TestNewTokenFileAuthMethodWithoutHome(t *testing.T) {
+	t.Setenv("HOME", "")
+
+	if _, _, err := NewTokenFileAuthMethod(nil).GetToken(context.Background()); err == nil {
+		t.Error("GetToken(): expected an error when the default token file can't be located")
+	}
+}
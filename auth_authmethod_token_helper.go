@@ -0,0 +1,227 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Authentication method for using a vault CLI token helper
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/getoutreach/vault-client/internal/fileutil"
+	"github.com/hashicorp/hcl"
+	"github.com/pkg/errors"
+)
+
+// vaultConfigPathEnv is the environment variable that overrides the path of
+// the vault CLI config file
+const vaultConfigPathEnv = "VAULT_CONFIG_PATH"
+
+// defaultVaultConfigFileName is the default file name, in the home
+// directory, of the vault CLI config file
+const defaultVaultConfigFileName = ".vault"
+
+// VaultCLIConfig is the vault CLI config file, usually ~/.vault
+type VaultCLIConfig struct {
+	// TokenHelper is the path of the token helper that stores the token
+	TokenHelper string `hcl:"token_helper"`
+}
+
+// LoadVaultCLIConfig loads the vault CLI config file at path. If path is
+// empty VAULT_CONFIG_PATH, or ~/.vault, is used. A config file that doesn't
+// exist is treated as empty.
+func LoadVaultCLIConfig(path string) (*VaultCLIConfig, error) {
+	if path == "" {
+		path = os.Getenv(vaultConfigPathEnv)
+	}
+
+	if path == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to locate vault config file")
+		}
+		path = filepath.Join(homeDir, defaultVaultConfigFileName)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to read vault config file at '%s'", path)
+	}
+
+	var conf VaultCLIConfig
+	if err := hcl.Decode(&conf, string(b)); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse vault config file at '%s'", path)
+	}
+	return &conf, nil
+}
+
+// TokenHelperAuthMethod implements a AuthMethod backed by the token helper
+// the vault CLI is configured with. Like the vault CLI, if no token helper
+// is configured the token is stored in ~/.vault-token.
+type TokenHelperAuthMethod struct {
+	// helper is the path of the token helper, if empty tokenFilePath is
+	// used instead
+	helper        string
+	tokenFilePath string
+
+	// err is returned by every operation if the token helper couldn't be
+	// located
+	err error
+
+	// opts are the options of the client using this auth method, used
+	// to lookup the token against the same Vault instance.
+	opts *Options
+}
+
+// NewTokenHelperAuthMethod returns a new TokenHelperAuthMethod using the
+// token helper configured in the vault CLI config file at configPath. If
+// configPath is nil VAULT_CONFIG_PATH, or ~/.vault, is used.
+func NewTokenHelperAuthMethod(configPath *string) *TokenHelperAuthMethod {
+	path := ""
+	if configPath != nil {
+		path = *configPath
+	}
+
+	conf, err := LoadVaultCLIConfig(path)
+	if err != nil {
+		return &TokenHelperAuthMethod{err: err}
+	}
+
+	if conf.TokenHelper == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return &TokenHelperAuthMethod{err: errors.Wrap(err, "failed to locate vault token file")}
+		}
+		return &TokenHelperAuthMethod{tokenFilePath: filepath.Join(homeDir, defaultFileName)}
+	}
+
+	helper, err := filepath.Abs(conf.TokenHelper)
+	if err != nil {
+		return &TokenHelperAuthMethod{err: errors.Wrapf(err, "failed to locate token helper '%s'", conf.TokenHelper)}
+	}
+	return &TokenHelperAuthMethod{helper: helper}
+}
+
+// Path returns the path of the token helper, or of the token file if no
+// token helper is configured
+func (a *TokenHelperAuthMethod) Path() string {
+	if a.helper != "" {
+		return a.helper
+	}
+	return a.tokenFilePath
+}
+
+// GetToken returns the token stored by the token helper while implementing
+// AuthMethod.GetToken(). An error is returned if no token is stored.
+func (a *TokenHelperAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	token, err := a.Get(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if token == "" {
+		return "", time.Time{}, errors.Errorf("no vault token stored by '%s'", a.Path())
+	}
+
+	expiresAt, err := staticTokenExpiry(ctx, a.opts, token)
+	if err != nil {
+		return "", time.Time{}, errors.Wrapf(err, "failed to lookup vault token stored by '%s'", a.Path())
+	}
+	return token, expiresAt, nil
+}
+
+// Options stores the client's options so the token can be looked up
+// using the same address and namespace.
+func (a *TokenHelperAuthMethod) Options(o *Options) {
+	a.opts = o
+}
+
+// Get returns the token stored by the token helper, it's empty if there is
+// none.
+func (a *TokenHelperAuthMethod) Get(ctx context.Context) (cfg.SecretData, error) {
+	if a.err != nil {
+		return "", a.err
+	}
+
+	if a.helper == "" {
+		b, err := os.ReadFile(a.tokenFilePath)
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read vault token at '%s'", a.tokenFilePath)
+		}
+		return cfg.SecretData(strings.TrimSpace(string(b))), nil
+	}
+
+	out, err := a.run(ctx, "get", nil)
+	if err != nil {
+		return "", err
+	}
+	return cfg.SecretData(strings.TrimSpace(string(out))), nil
+}
+
+// Store stores token with the token helper, replacing the current token
+func (a *TokenHelperAuthMethod) Store(ctx context.Context, token cfg.SecretData) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	if a.helper == "" {
+		return errors.Wrapf(fileutil.WriteFileAtomic(a.tokenFilePath, []byte(token)), "failed to write vault token at '%s'", a.tokenFilePath)
+	}
+
+	_, err := a.run(ctx, "store", []byte(token))
+	return err
+}
+
+// Erase removes the token stored by the token helper
+func (a *TokenHelperAuthMethod) Erase(ctx context.Context) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	if a.helper == "" {
+		if err := os.Remove(a.tokenFilePath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove vault token at '%s'", a.tokenFilePath)
+		}
+		return nil
+	}
+
+	_, err := a.run(ctx, "erase", nil)
+	return err
+}
+
+// run runs the token helper with the provided operation, the same way the
+// vault CLI does, and returns its output.
+func (a *TokenHelperAuthMethod) run(ctx context.Context, op string, stdin []byte) ([]byte, error) {
+	shell, flag := "/bin/sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	if s := os.Getenv("SHELL"); s != "" {
+		shell = s
+	}
+
+	//nolint:gosec // Why: the token helper is configured by the user
+	cmd := exec.CommandContext(ctx, shell, flag, strings.ReplaceAll(a.helper, `\`, `\\`)+" "+op)
+	cmd.Env = os.Environ()
+	if a.opts != nil && a.opts.Host != "" {
+		// token helpers may store a token per Vault server
+		cmd.Env = append(cmd.Env, "VAULT_ADDR="+a.opts.Host)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "token helper '%s' failed to %s token: %s", a.helper, op, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
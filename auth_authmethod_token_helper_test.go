@@ -0,0 +1,153 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeTokenHelper is a token helper that stores the token, and the
+// VAULT_ADDR it was stored for, next to itself
+const fakeTokenHelper = `#!/bin/sh
+dir=$(dirname "$0")
+case "$1" in
+get) cat "$dir/token" 2>/dev/null || true ;;
+store) cat > "$dir/token"; echo "$VAULT_ADDR" > "$dir/addr" ;;
+erase) rm -f "$dir/token" ;;
+*) echo "unknown operation" >&2; exit 1 ;;
+esac
+`
+
+// writeTestVaultConfig writes a vault CLI config file, with a fake token
+// helper, and returns its path and the directory of the helper
+func writeTestVaultConfig(t *testing.T) (configPath, helperDir string) {
+	t.Helper()
+
+	helperDir = t.TempDir()
+	helper := filepath.Join(helperDir, "helper")
+	if err := os.WriteFile(helper, []byte(fakeTokenHelper), 0o700); err != nil {
+		t.Fatalf("Failed to write token helper: os.WriteFile() = %v", err)
+	}
+
+	configPath = filepath.Join(t.TempDir(), ".vault")
+	if err := os.WriteFile(configPath, []byte("token_helper = \""+helper+"\"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write vault config: os.WriteFile() = %v", err)
+	}
+	return configPath, helperDir
+}
+
+func TestTokenHelperAuthMethod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake token helper is a shell script")
+	}
+
+	ctx := context.Background()
+	configPath, helperDir := writeTestVaultConfig(t)
+
+	a := NewTokenHelperAuthMethod(&configPath)
+	a.Options(&Options{Host: "https://vault.example.com"})
+
+	if a.Path() != filepath.Join(helperDir, "helper") {
+		t.Errorf("Path(): expected the configured token helper, got %q", a.Path())
+	}
+
+	if token, err := a.Get(ctx); err != nil || token != "" {
+		t.Errorf("Get(): expected no token, got %q, %v", token, err)
+	}
+
+	if _, _, err := a.GetToken(ctx); err == nil {
+		t.Error("GetToken(): expected an error without a stored token")
+	}
+
+	if err := a.Store(ctx, "token"); err != nil {
+		t.Errorf("Store() = %v", err)
+		return
+	}
+
+	if token, err := a.Get(ctx); err != nil || token != "token" {
+		t.Errorf("Get(): expected the stored token, got %q, %v", token, err)
+	}
+
+	addr, err := os.ReadFile(filepath.Join(helperDir, "addr"))
+	if err != nil || string(addr) != "https://vault.example.com\n" {
+		t.Errorf("Store(): expected VAULT_ADDR to be passed to the token helper, got %q, %v", addr, err)
+	}
+
+	if err := a.Erase(ctx); err != nil {
+		t.Errorf("Erase() = %v", err)
+		return
+	}
+
+	if token, err := a.Get(ctx); err != nil || token != "" {
+		t.Errorf("Get(): expected no token after Erase(), got %q, %v", token, err)
+	}
+}
+
+func TestTokenHelperAuthMethod_TokenFile(t *testing.T) {
+	ctx := context.Background()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(vaultConfigPathEnv, "")
+
+	// without a config file ~/.vault-token is used, like the vault CLI does
+	a := NewTokenHelperAuthMethod(nil)
+	tokenFile := filepath.Join(home, ".vault-token")
+	if a.Path() != tokenFile {
+		t.Errorf("Path(): expected %q, got %q", tokenFile, a.Path())
+	}
+
+	if err := a.Store(ctx, "token"); err != nil {
+		t.Errorf("Store() = %v", err)
+		return
+	}
+
+	fi, err := os.Stat(tokenFile)
+	if err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("Store(): expected the token file to be written with 0600, got %v, %v", fi, err)
+	}
+
+	if token, err := a.Get(ctx); err != nil || token != "token" {
+		t.Errorf("Get(): expected the stored token, got %q, %v", token, err)
+	}
+
+	if err := a.Erase(ctx); err != nil {
+		t.Errorf("Erase() = %v", err)
+		return
+	}
+
+	if _, err := os.Stat(tokenFile); !os.IsNotExist(err) {
+		t.Errorf("Erase(): expected the token file to be removed, got %v", err)
+	}
+}
+
+func TestLoadVaultCLIConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	conf, err := LoadVaultCLIConfig(filepath.Join(dir, "missing"))
+	if err != nil || conf.TokenHelper != "" {
+		t.Errorf("LoadVaultCLIConfig(): expected an empty config for a missing file, got %+v, %v", conf, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid")
+	if err := os.WriteFile(invalid, []byte("token_helper = {"), 0o600); err != nil {
+		t.Fatalf("Failed to write vault config: os.WriteFile() = %v", err)
+	}
+
+	if _, err := LoadVaultCLIConfig(invalid); err == nil {
+		t.Error("LoadVaultCLIConfig(): expected an error for an invalid config")
+	}
+
+	t.Setenv(vaultConfigPathEnv, filepath.Join(dir, "env"))
+	if err := os.WriteFile(filepath.Join(dir, "env"), []byte(`token_helper = "/usr/bin/helper"`), 0o600); err != nil {
+		t.Fatalf("Failed to write vault config: os.WriteFile() = %v", err)
+	}
+
+	conf, err = LoadVaultCLIConfig("")
+	if err != nil || conf.TokenHelper != "/usr/bin/helper" {
+		t.Errorf("LoadVaultCLIConfig(): expected the config at VAULT_CONFIG_PATH, got %+v, %v", conf, err)
+	}
+}
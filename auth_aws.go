@@ -0,0 +1,220 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to interact with basic /auth/aws endpoints
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-secure-stdlib/awsutil"
+	"github.com/pkg/errors"
+)
+
+// defaultAWSMount is the default mount of the aws auth method
+const defaultAWSMount = "aws"
+
+// defaultAWSRegion is the region requests are signed for if none is
+// configured
+const defaultAWSRegion = "us-east-1"
+
+// AWSCredentials are AWS credentials used to sign the login request
+type AWSCredentials struct {
+	// AccessKeyID is the AWS access key ID
+	AccessKeyID string
+
+	// SecretAccessKey is the AWS secret access key
+	SecretAccessKey cfg.SecretData
+
+	// SessionToken is the session token of temporary credentials, if any
+	SessionToken cfg.SecretData
+}
+
+// AWSCredentialsProvider provides the AWS credentials used to sign the
+// login request, it's called on every login so rotated credentials are
+// picked up. It can be implemented on top of any AWS SDK.
+type AWSCredentialsProvider interface {
+	// Retrieve returns the current AWS credentials
+	Retrieve(ctx context.Context) (*AWSCredentials, error)
+}
+
+// AWSIAMAuthOptions are options for an AWSIAMAuthMethod
+type AWSIAMAuthOptions struct {
+	// Role is the Vault role to login as, if empty Vault uses the name of
+	// the IAM principal
+	Role string
+
+	// Mount is the mount of the aws auth method, defaults to aws
+	Mount string
+
+	// Region is the region of the STS endpoint the request is signed for,
+	// defaults to AWS_REGION, then AWS_DEFAULT_REGION, then us-east-1. The
+	// EC2 metadata endpoint is never queried for the region.
+	Region string
+
+	// ServerID is sent as the signed X-Vault-AWS-IAM-Server-ID header, it
+	// must match the iam_server_id_header_value of the mount if that's set.
+	ServerID string
+
+	// AccessKeyID, SecretAccessKey and SessionToken are static AWS
+	// credentials used to sign the request
+	AccessKeyID     string
+	SecretAccessKey cfg.SecretData
+	SessionToken    cfg.SecretData
+
+	// CredentialsProvider provides the AWS credentials used to sign the
+	// request, it's used instead of the static credentials. If neither is
+	// set the standard AWS credential sources are used: environment
+	// variables, shared credentials, web identity and the EC2/ECS metadata
+	// endpoints.
+	CredentialsProvider AWSCredentialsProvider
+}
+
+// staticAWSCredentials implements an AWSCredentialsProvider that always
+// returns the same credentials
+type staticAWSCredentials AWSCredentials
+
+// Retrieve returns the static credentials
+func (s *staticAWSCredentials) Retrieve(context.Context) (*AWSCredentials, error) {
+	return (*AWSCredentials)(s), nil
+}
+
+// awsCredentialChain implements an AWSCredentialsProvider using the
+// standard AWS credential sources
+type awsCredentialChain struct {
+	region string
+
+	mu    sync.Mutex
+	creds *credentials.Credentials
+}
+
+// Retrieve returns the credentials of the first credential source that
+// provides them, the chain is created on first use.
+func (a *awsCredentialChain) Retrieve(ctx context.Context) (*AWSCredentials, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.creds == nil {
+		conf, err := awsutil.NewCredentialsConfig(awsutil.WithRegion(a.region))
+		if err != nil {
+			return nil, err
+		}
+
+		if a.creds, err = conf.GenerateCredentialChain(); err != nil {
+			return nil, err
+		}
+	}
+
+	v, err := a.creds.GetWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AWSCredentials{
+		AccessKeyID:     v.AccessKeyID,
+		SecretAccessKey: cfg.SecretData(v.SecretAccessKey),
+		SessionToken:    cfg.SecretData(v.SessionToken),
+	}, nil
+}
+
+// awsRegion returns region, or the region of the environment, or
+// us-east-1
+func awsRegion(region string) string {
+	for _, r := range []string{region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")} {
+		if r != "" {
+			return r
+		}
+	}
+	return defaultAWSRegion
+}
+
+// AWSIAMAuthMethod implements a AuthMethod backed by AWS IAM credentials.
+// A sts:GetCallerIdentity request is signed locally and Vault uses it to
+// verify the identity of the caller.
+type AWSIAMAuthMethod struct {
+	c *Client
+
+	role     string
+	mount    string
+	region   string
+	serverID string
+	creds    AWSCredentialsProvider
+}
+
+// NewAWSIAMAuthMethod returns a new AWSIAMAuthMethod based on the provided
+// options.
+func NewAWSIAMAuthMethod(opts *AWSIAMAuthOptions) *AWSIAMAuthMethod {
+	region := awsRegion(opts.Region)
+
+	var creds AWSCredentialsProvider
+	switch {
+	case opts.CredentialsProvider != nil:
+		creds = opts.CredentialsProvider
+	case opts.AccessKeyID != "":
+		creds = &staticAWSCredentials{
+			AccessKeyID:     opts.AccessKeyID,
+			SecretAccessKey: opts.SecretAccessKey,
+			SessionToken:    opts.SessionToken,
+		}
+	default:
+		creds = &awsCredentialChain{region: region}
+	}
+
+	return &AWSIAMAuthMethod{
+		role:     opts.Role,
+		mount:    authMount(opts.Mount, defaultAWSMount),
+		region:   region,
+		serverID: opts.ServerID,
+		creds:    creds,
+	}
+}
+
+func (a *AWSIAMAuthMethod) Options(o *Options) {
+	a.c = New(withInheritedOptions(o))
+}
+
+// GetToken returns a token for the current AWS credentials. A new request
+// is signed on every login, since signed requests are only valid for a
+// short time and the credentials may have been rotated.
+func (a *AWSIAMAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	creds, err := a.creds.Retrieve(ctx)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to get aws credentials")
+	}
+
+	auth, err := a.c.AWSIAMLogin(ctx, a.mount, a.role, creds, a.region, a.serverID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, expiresAt := authToken(auth)
+	return token, expiresAt, nil
+}
+
+// AWSIAMLogin creates a new token using the provided aws auth method mount
+// and role by signing a sts:GetCallerIdentity request with creds for the
+// provided region, which defaults to us-east-1 if it's empty. If serverID
+// is set it's included, and signed, as the X-Vault-AWS-IAM-Server-ID header.
+func (c *Client) AWSIAMLogin(ctx context.Context, mount, role string, creds *AWSCredentials,
+	region, serverID string) (*SecretAuth, error) {
+	if region == "" {
+		region = defaultAWSRegion
+	}
+
+	v1Creds := credentials.NewStaticCredentials(creds.AccessKeyID, string(creds.SecretAccessKey), string(creds.SessionToken))
+	body, err := awsutil.GenerateLoginData(v1Creds, serverID, region, hclog.NewNullLogger())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign sts:GetCallerIdentity request")
+	}
+
+	if role != "" {
+		body["role"] = role
+	}
+	return c.authLogin(ctx, path.Join("auth", mount, "login"), body)
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAWSIAMAuthMethod(t *testing.T) {
+	var login map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/aws-iam/login" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`)) //nolint:errcheck // Why: test server
+			return
+		}
+
+		json.NewDecoder(r.Body).Decode(&login)                                                      //nolint:errcheck // Why: test server
+		w.Write([]byte(`{"auth":{"client_token":"token","lease_duration":3600,"renewable":true}}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	c := New(WithAddress(srv.URL), WithAWSIAMAuth(&AWSIAMAuthOptions{
+		Role:            "app",
+		Mount:           "aws-iam",
+		Region:          "us-west-2",
+		ServerID:        "vault.example.com",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	}))
+
+	token, expiresAt, err := c.opts.am.GetToken(context.Background())
+	if err != nil {
+		t.Errorf("GetToken() = %v", err)
+		return
+	}
+
+	if token != "token" || expiresAt.IsZero() {
+		t.Errorf("GetToken(): expected an expiring token, got %q expiring at %v", token, expiresAt)
+	}
+
+	decode := func(field string) string {
+		b, err := base64.StdEncoding.DecodeString(login[field])
+		if err != nil {
+			t.Errorf("failed to decode %s: %v", field, err)
+		}
+		return string(b)
+	}
+
+	if login["role"] != "app" || login["iam_http_request_method"] != http.MethodPost {
+		t.Errorf("unexpected login request: %v", login)
+	}
+
+	if got := decode("iam_request_url"); got != "https://sts.amazonaws.com/" {
+		t.Errorf("expected the sts url, got %q", got)
+	}
+
+	if got := decode("iam_request_body"); got != "Action=GetCallerIdentity&Version=2011-06-15" {
+		t.Errorf("expected a sts:GetCallerIdentity request, got %q", got)
+	}
+
+	var headers http.Header
+	if err := json.Unmarshal([]byte(decode("iam_request_headers")), &headers); err != nil {
+		t.Errorf("failed to decode headers: %v", err)
+		return
+	}
+
+	if headers.Get("X-Vault-AWS-IAM-Server-ID") != "vault.example.com" {
+		t.Errorf("expected the server id header to be set, got %v", headers)
+	}
+
+	authz := headers.Get("Authorization")
+	if !strings.Contains(authz, "Credential=AKIDEXAMPLE/") || !strings.Contains(authz, "/us-west-2/sts/aws4_request") ||
+		!strings.Contains(authz, "x-vault-aws-iam-server-id") {
+		t.Errorf("expected the request, including the server id header, to be signed, got %q", authz)
+	}
+}
+
+// awsCredentialsFunc implements an AWSCredentialsProvider with a function
+type awsCredentialsFunc func(ctx context.Context) (*AWSCredentials, error)
+
+// Retrieve calls the function
+func (f awsCredentialsFunc) Retrieve(ctx context.Context) (*AWSCredentials, error) {
+	return f(ctx)
+}
+
+func TestAWSIAMAuthMethod_CredentialsProvider(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	var authz string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var login map[string]string
+		json.NewDecoder(r.Body).Decode(&login) //nolint:errcheck // Why: test server
+
+		b, _ := base64.StdEncoding.DecodeString(login["iam_request_headers"]) //nolint:errcheck // Why: checked below
+		var headers http.Header
+		json.Unmarshal(b, &headers) //nolint:errcheck // Why: checked below
+		authz = headers.Get("Authorization")
+
+		w.Write([]byte(`{"auth":{"client_token":"token","lease_duration":3600}}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	calls := 0
+	provider := awsCredentialsFunc(func(context.Context) (*AWSCredentials, error) {
+		calls++
+		return &AWSCredentials{AccessKeyID: fmt.Sprintf("AKID%d", calls), SecretAccessKey: "secret", SessionToken: "session"}, nil
+	})
+
+	c := New(WithAddress(srv.URL), WithAWSIAMAuth(&AWSIAMAuthOptions{CredentialsProvider: provider}))
+	for i := 1; i <= 2; i++ {
+		if _, _, err := c.opts.am.GetToken(context.Background()); err != nil {
+			t.Errorf("GetToken() = %v", err)
+			return
+		}
+
+		// the provider is called on every login, and the region defaults
+		// to us-east-1 without looking it up
+		if !strings.Contains(authz, fmt.Sprintf("Credential=AKID%d/", i)) || !strings.Contains(authz, "/us-east-1/sts/aws4_request") {
+			t.Errorf("expected the request to be signed with the provided credentials for us-east-1, got %q", authz)
+		}
+	}
+
+	failing := New(WithAddress(srv.URL), WithAWSIAMAuth(&AWSIAMAuthOptions{
+		CredentialsProvider: awsCredentialsFunc(func(context.Context) (*AWSCredentials, error) {
+			return nil, fmt.Errorf("no credentials")
+		}),
+	}))
+	if _, _, err := failing.opts.am.GetToken(context.Background()); err == nil || !strings.Contains(err.Error(), "no credentials") {
+		t.Errorf("GetToken(): expected the provider error, got %v", err)
+	}
+}
+
+func TestAWSRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	if got := awsRegion(""); got != "us-east-1" {
+		t.Errorf("awsRegion(): expected us-east-1 by default, got %q", got)
+	}
+
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+	if got := awsRegion(""); got != "eu-west-1" {
+		t.Errorf("awsRegion(): expected AWS_DEFAULT_REGION, got %q", got)
+	}
+
+	t.Setenv("AWS_REGION", "us-west-2")
+	if got := awsRegion(""); got != "us-west-2" {
+		t.Errorf("awsRegion(): expected AWS_REGION, got %q", got)
+	}
+
+	if got := awsRegion("ap-south-1"); got != "ap-south-1" {
+		t.Errorf("awsRegion(): expected the configured region, got %q", got)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to interact with basic /auth/cert endpoints
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+)
+
+// defaultCertMount is the default mount of the cert auth method
+const defaultCertMount = "cert"
+
+// CertAuthOptions are options for a CertAuthMethod
+type CertAuthOptions struct {
+	// Name is the cert role to login as, if empty Vault tries every role
+	// that trusts the client certificate
+	Name string
+
+	// Mount is the mount of the cert auth method, defaults to cert
+	Mount string
+}
+
+// CertAuthMethod implements a AuthMethod backed by the client certificate
+// configured on the Client, see WithClientCert.
+type CertAuthMethod struct {
+	c *Client
+
+	name  string
+	mount string
+}
+
+// NewCertAuthMethod returns a new CertAuthMethod based on the provided
+// options.
+func NewCertAuthMethod(opts *CertAuthOptions) *CertAuthMethod {
+	return &CertAuthMethod{
+		name:  opts.Name,
+		mount: authMount(opts.Mount, defaultCertMount),
+	}
+}
+
+// Options creates the client used to login, it shares the TLS settings,
+// and so the client certificate, of the provided options.
+func (a *CertAuthMethod) Options(o *Options) {
+	a.c = New(withInheritedOptions(o))
+}
+
+// GetToken returns a token for the client certificate
+func (a *CertAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	auth, err := a.c.CertLogin(ctx, a.mount, a.name)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, expiresAt := authToken(auth)
+	return token, expiresAt, nil
+}
+
+// CertLogin creates a new token using the client certificate configured on
+// the Client and the provided cert auth method mount and role name
+func (c *Client) CertLogin(ctx context.Context, mount, name string) (*SecretAuth, error) {
+	body := map[string]string{}
+	if name != "" {
+		body["name"] = name
+	}
+	return c.authLogin(ctx, path.Join("auth", mount, "login"), body)
+}
+
+// CreateCertRoleOptions are options to provide to CreateCertRole, docs:
+// https://developer.hashicorp.com/vault/api-docs/auth/cert#create-ca-certificate-role
+type CreateCertRoleOptions struct {
+	// Name is the name of the role to create
+	Name string `json:"-"`
+
+	// Mount is the mount of the cert auth method, defaults to cert
+	Mount string `json:"-"`
+
+	// Certificate is the PEM encoded CA certificate used to verify client
+	// certificates
+	Certificate string `json:"certificate"`
+
+	DisplayName                string   `json:"display_name,omitempty"`
+	AllowedCommonNames         []string `json:"allowed_common_names,omitempty"`
+	AllowedDNSSANs             []string `json:"allowed_dns_sans,omitempty"`
+	AllowedEmailSANs           []string `json:"allowed_email_sans,omitempty"`
+	AllowedURISANs             []string `json:"allowed_uri_sans,omitempty"`
+	AllowedOrganizationalUnits []string `json:"allowed_organizational_units,omitempty"`
+	TokenTTL                   string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL                string   `json:"token_max_ttl,omitempty"`
+	TokenPolicies              []string `json:"token_policies,omitempty"`
+}
+
+// CreateCertRole creates, or updates, a cert auth role in Vault
+func (c *Client) CreateCertRole(ctx context.Context, opts *CreateCertRoleOptions) error {
+	return c.doRequest(ctx, http.MethodPost, path.Join("auth", authMount(opts.Mount, defaultCertMount), "certs", opts.Name), opts, nil)
+}
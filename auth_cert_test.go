@@ -0,0 +1,70 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCertAuthMethod(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil)
+	client := newTestCert(t, dir, "client", ca)
+
+	var loginCN string
+	var login, role map[string]interface{}
+	srv := newTestTLSServer(t, ca, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/cert/login":
+			loginCN = r.TLS.PeerCertificates[0].Subject.CommonName
+			json.NewDecoder(r.Body).Decode(&login)                                     //nolint:errcheck // Why: test server
+			w.Write([]byte(`{"auth":{"client_token":"token","lease_duration":3600}}`)) //nolint:errcheck // Why: test server
+		case "/v1/auth/cert/certs/web":
+			json.NewDecoder(r.Body).Decode(&role) //nolint:errcheck // Why: test server
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Write([]byte(`{"data":{"id":"token"}}`)) //nolint:errcheck // Why: test server
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(WithAddress(srv.URL), WithCACert(ca.CertFile), WithClientCert(client.CertFile, client.KeyFile),
+		WithCertAuth(&CertAuthOptions{Name: "web"}))
+
+	if err := c.CreateCertRole(ctx, &CreateCertRoleOptions{
+		Name:               "web",
+		Certificate:        string(ca.PEM()),
+		AllowedCommonNames: []string{"client"},
+		TokenPolicies:      []string{"web"},
+	}); err != nil {
+		t.Errorf("CreateCertRole() = %v", err)
+		return
+	}
+
+	if _, err := c.LookupCurrentToken(ctx); err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	if loginCN != "client" {
+		t.Errorf("expected login to present the client certificate, got %q", loginCN)
+	}
+
+	if diff := cmp.Diff(map[string]interface{}{"name": "web"}, login); diff != "" {
+		t.Errorf("login: %s", diff)
+	}
+
+	wantRole := map[string]interface{}{
+		"certificate":          string(ca.PEM()),
+		"allowed_common_names": []interface{}{"client"},
+		"token_policies":       []interface{}{"web"},
+	}
+	if diff := cmp.Diff(wantRole, role); diff != "" {
+		t.Errorf("role: %s", diff)
+	}
+}
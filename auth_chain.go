@@ -0,0 +1,142 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements an AuthMethod that falls back through multiple AuthMethods
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
+)
+
+// AuthMethodError is the reason an AuthMethod in a ChainAuthMethod failed
+type AuthMethodError struct {
+	// Method is the AuthMethod that failed
+	Method AuthMethod
+
+	// Err is the error returned by Method
+	Err error
+}
+
+// Error implements the error interface
+func (e *AuthMethodError) Error() string {
+	return fmt.Sprintf("%T: %v", e.Method, e.Err)
+}
+
+// Unwrap returns the error returned by the AuthMethod
+func (e *AuthMethodError) Unwrap() error {
+	return e.Err
+}
+
+// ChainAuthError is returned by ChainAuthMethod.GetToken when every
+// AuthMethod failed, it contains the reason each of them failed in order.
+type ChainAuthError []*AuthMethodError
+
+// Error implements the error interface
+func (e ChainAuthError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "all auth methods failed: " + strings.Join(msgs, "; ")
+}
+
+// ChainAuthMethod implements a AuthMethod that tries multiple AuthMethods in
+// order, e.g. kubernetes, then approle, then a token file. The AuthMethod that
+// succeeded is remembered and tried first on subsequent logins.
+type ChainAuthMethod struct {
+	methods []AuthMethod
+
+	mu sync.Mutex
+
+	// active is the index of the AuthMethod that provided the last token,
+	// or -1 if there is none
+	active   int
+	failures []*AuthMethodError
+}
+
+// NewChainAuthMethod returns a new ChainAuthMethod that tries the provided
+// AuthMethods in order
+func NewChainAuthMethod(methods ...AuthMethod) *ChainAuthMethod {
+	return &ChainAuthMethod{methods: methods, active: -1}
+}
+
+// Options passes the client options to every AuthMethod in the chain
+func (a *ChainAuthMethod) Options(o *Options) {
+	for _, m := range a.methods {
+		m.Options(o)
+	}
+}
+
+// GetToken returns a token from the first AuthMethod that's able to provide
+// one. An AuthMethod returning an empty token is treated as having failed.
+// If every AuthMethod fails a ChainAuthError is returned.
+func (a *ChainAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	if len(a.methods) == 0 {
+		return "", time.Time{}, errors.New("no auth methods in chain")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var failures ChainAuthError
+	for _, i := range a.order() {
+		m := a.methods[i]
+		token, expiresAt, err := m.GetToken(ctx)
+		if err == nil && token == "" {
+			err = errors.New("no token returned")
+		}
+
+		if err != nil {
+			failures = append(failures, &AuthMethodError{Method: m, Err: err})
+			continue
+		}
+
+		a.active, a.failures = i, failures
+		return token, expiresAt, nil
+	}
+
+	a.active, a.failures = -1, failures
+	return "", time.Time{}, failures
+}
+
+// order returns the indexes of the AuthMethods in the order they should be
+// tried, the active AuthMethod first. a.mu must be held.
+func (a *ChainAuthMethod) order() []int {
+	order := make([]int, 0, len(a.methods))
+	if a.active >= 0 {
+		order = append(order, a.active)
+	}
+
+	for i := range a.methods {
+		if i != a.active {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// Active returns the AuthMethod that provided the last token, or nil if no
+// token was obtained yet.
+func (a *ChainAuthMethod) Active() AuthMethod {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.active < 0 {
+		return nil
+	}
+	return a.methods[a.active]
+}
+
+// Failures returns why the AuthMethods tried before the active AuthMethod
+// failed during the last login.
+func (a *ChainAuthMethod) Failures() []*AuthMethodError {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*AuthMethodError(nil), a.failures...)
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
+)
+
+// funcAuthMethod is an AuthMethod backed by a function
+type funcAuthMethod func(ctx context.Context) (cfg.SecretData, time.Time, error)
+
+// GetToken calls the function
+func (f funcAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	return f(ctx)
+}
+
+// Options implements AuthMethod
+func (f funcAuthMethod) Options(*Options) {}
+
+func TestChainAuthMethod(t *testing.T) {
+	ctx := context.Background()
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	primaryErr := errors.New("primary unavailable")
+	primaryCalls := 0
+	primary := funcAuthMethod(func(context.Context) (cfg.SecretData, time.Time, error) {
+		primaryCalls++
+		return "", time.Time{}, primaryErr
+	})
+
+	fallbackCalls := 0
+	fallback := funcAuthMethod(func(context.Context) (cfg.SecretData, time.Time, error) {
+		fallbackCalls++
+		return "fallback", time.Time{}, nil
+	})
+
+	chain := NewChainAuthMethod(primary, NewTokenFileAuthMethod(&missing), NewTokenAuthMethod(""), fallback)
+	chain.Options(&Options{})
+
+	token, _, err := chain.GetToken(ctx)
+	if err != nil || token != "fallback" {
+		t.Errorf("GetToken(): expected the fallback token, got %q, %v", token, err)
+		return
+	}
+
+	if _, ok := chain.Active().(funcAuthMethod); !ok {
+		t.Errorf("Active(): expected the fallback to be active, got %T", chain.Active())
+	}
+
+	failures := chain.Failures()
+	if len(failures) != 3 || !errors.Is(failures[0], primaryErr) {
+		t.Errorf("Failures(): expected the 3 earlier auth methods to have failed, got %v", failures)
+	}
+
+	// the active auth method is tried first on subsequent logins
+	if _, _, err := chain.GetToken(ctx); err != nil {
+		t.Errorf("GetToken() = %v", err)
+		return
+	}
+
+	if primaryCalls != 1 || fallbackCalls != 2 {
+		t.Errorf("expected the active auth method to be tried first, got %d primary and %d fallback calls",
+			primaryCalls, fallbackCalls)
+	}
+
+	_, _, err = NewChainAuthMethod(primary, NewTokenAuthMethod("")).GetToken(ctx)
+
+	var chainErr ChainAuthError
+	if !errors.As(err, &chainErr) || len(chainErr) != 2 || !errors.Is(chainErr[0], primaryErr) {
+		t.Errorf("GetToken(): expected a ChainAuthError for every auth method, got %v", err)
+	}
+}
+
+func TestChainAuthMethod_InvalidTokenFile(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeApproleVault(t)
+	f.revoke("revoked")
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("revoked\n"), 0o600); err != nil {
+		t.Errorf("failed to write token file: %v", err)
+		return
+	}
+
+	// the token file holds a token Vault rejects, so the chain has to fall
+	// back to logging in with approle
+	chain := NewChainAuthMethod(NewTokenFileAuthMethod(&path), NewApproleAuthMethod("role-id", "secret-id"))
+	c := New(WithAddress(f.URL), func(o *Options) { o.am = chain })
+
+	info, err := c.LookupCurrentToken(ctx)
+	if err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	if info.ID != "token-1" {
+		t.Errorf("expected the approle token to be used, got %q", info.ID)
+	}
+
+	failures := chain.Failures()
+	if len(failures) != 1 || !IsPermissionDenied(failures[0]) {
+		t.Errorf("Failures(): expected the token file to be rejected by Vault, got %v", failures)
+	}
+}
+
+func TestChainAuthMethod_Empty(t *testing.T) {
+	_, _, err := NewChainAuthMethod().GetToken(context.Background())
+	if err == nil {
+		t.Error("GetToken(): expected an error for an empty chain")
+		return
+	}
+
+	var chainErr ChainAuthError
+	if errors.As(err, &chainErr) {
+		t.Errorf("GetToken(): expected a plain error for an empty chain, got %T", err)
+	}
+}
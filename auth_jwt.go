@@ -0,0 +1,234 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to interact with basic /auth/jwt endpoints
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
+)
+
+// defaultJWTMount is the default mount of the jwt auth method
+const defaultJWTMount = "jwt"
+
+// JWTFunc returns the JWT to login with, e.g. a CI OIDC token or a SPIFFE
+// JWT-SVID
+type JWTFunc func(ctx context.Context) (cfg.SecretData, error)
+
+// JWTAuthOptions are options for a JWTAuthMethod. Exactly one of Func,
+// Path or Env should be set, they're used in that order.
+type JWTAuthOptions struct {
+	// Role is the Vault role to login as, if empty the mount's
+	// default_role is used
+	Role string
+
+	// Mount is the mount of the jwt auth method, defaults to jwt
+	Mount string
+
+	// Func returns the JWT to login with
+	Func JWTFunc
+
+	// Path is the path of a file containing the JWT, it's read on every
+	// login
+	Path string
+
+	// Env is the name of an environment variable containing the JWT
+	Env string
+}
+
+// JWTAuthMethod implements a AuthMethod backed by a JWT
+type JWTAuthMethod struct {
+	c *Client
+
+	role  string
+	mount string
+	jwt   JWTFunc
+}
+
+// NewJWTAuthMethod returns a new JWTAuthMethod based on the provided
+// options.
+func NewJWTAuthMethod(opts *JWTAuthOptions) *JWTAuthMethod {
+	return &JWTAuthMethod{
+		role:  opts.Role,
+		mount: authMount(opts.Mount, defaultJWTMount),
+		jwt:   opts.source(),
+	}
+}
+
+// source returns the JWTFunc for the configured source of the JWT
+func (o *JWTAuthOptions) source() JWTFunc {
+	switch {
+	case o.Func != nil:
+		return o.Func
+	case o.Path != "":
+		file := o.Path
+		return func(context.Context) (cfg.SecretData, error) {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to read jwt at '%s'", file)
+			}
+			return cfg.SecretData(strings.TrimSpace(string(b))), nil
+		}
+	case o.Env != "":
+		env := o.Env
+		return func(context.Context) (cfg.SecretData, error) {
+			jwt, ok := os.LookupEnv(env)
+			if !ok {
+				return "", errors.Errorf("jwt environment variable '%s' is not set", env)
+			}
+			return cfg.SecretData(strings.TrimSpace(jwt)), nil
+		}
+	default:
+		return func(context.Context) (cfg.SecretData, error) {
+			return "", errors.New("no jwt source configured")
+		}
+	}
+}
+
+func (a *JWTAuthMethod) Options(o *Options) {
+	a.c = New(withInheritedOptions(o))
+}
+
+// GetToken returns a token for the current JWT, the JWT is obtained again
+// on every login since they're usually short lived.
+func (a *JWTAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	jwt, err := a.jwt(ctx)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to get jwt")
+	}
+
+	auth, err := a.c.JWTLogin(ctx, a.mount, a.role, jwt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, expiresAt := authToken(auth)
+	return token, expiresAt, nil
+}
+
+// JWTLogin creates a new token using the provided jwt auth method mount,
+// role and JWT
+func (c *Client) JWTLogin(ctx context.Context, mount, role string, jwt cfg.SecretData) (*SecretAuth, error) {
+	body := map[string]string{"jwt": string(jwt)}
+	if role != "" {
+		body["role"] = role
+	}
+	return c.authLogin(ctx, path.Join("auth", mount, "login"), body)
+}
+
+// ConfigureJWTAuthOptions are options to provide to ConfigureJWTAuth, docs:
+// https://developer.hashicorp.com/vault/api-docs/auth/jwt#configure
+type ConfigureJWTAuthOptions struct {
+	// Mount is the mount of the jwt auth method, defaults to jwt
+	Mount string `json:"-"`
+
+	OIDCDiscoveryURL     string   `json:"oidc_discovery_url,omitempty"`
+	OIDCDiscoveryCAPEM   string   `json:"oidc_discovery_ca_pem,omitempty"`
+	OIDCClientID         string   `json:"oidc_client_id,omitempty"`
+	OIDCClientSecret     string   `json:"oidc_client_secret,omitempty"`
+	JWKSURL              string   `json:"jwks_url,omitempty"`
+	JWKSCAPEM            string   `json:"jwks_ca_pem,omitempty"`
+	JWTValidationPubKeys []string `json:"jwt_validation_pubkeys,omitempty"`
+	JWTSupportedAlgs     []string `json:"jwt_supported_algs,omitempty"`
+	BoundIssuer          string   `json:"bound_issuer,omitempty"`
+	DefaultRole          string   `json:"default_role,omitempty"`
+}
+
+// ConfigureJWTAuth configures a jwt auth method in Vault
+func (c *Client) ConfigureJWTAuth(ctx context.Context, opts *ConfigureJWTAuthOptions) error {
+	return c.doRequest(ctx, http.MethodPost, path.Join("auth", authMount(opts.Mount, defaultJWTMount), "config"), opts, nil)
+}
+
+// CreateJWTRoleOptions are options to provide to CreateJWTRole, docs:
+// https://developer.hashicorp.com/vault/api-docs/auth/jwt#create-update-role
+type CreateJWTRoleOptions struct {
+	// Name is the name of the role to create
+	Name string `json:"-"`
+
+	// Mount is the mount of the jwt auth method, defaults to jwt
+	Mount string `json:"-"`
+
+	RoleType            string                 `json:"role_type,omitempty"`
+	UserClaim           string                 `json:"user_claim"`
+	BoundAudiences      []string               `json:"bound_audiences,omitempty"`
+	BoundSubject        string                 `json:"bound_subject,omitempty"`
+	BoundClaims         map[string]interface{} `json:"bound_claims,omitempty"`
+	BoundClaimsType     string                 `json:"bound_claims_type,omitempty"`
+	ClaimMappings       map[string]string      `json:"claim_mappings,omitempty"`
+	AllowedRedirectURIs []string               `json:"allowed_redirect_uris,omitempty"`
+	OIDCScopes          []string               `json:"oidc_scopes,omitempty"`
+	TokenTTL            string                 `json:"token_ttl,omitempty"`
+	TokenMaxTTL         string                 `json:"token_max_ttl,omitempty"`
+	TokenPolicies       []string               `json:"token_policies,omitempty"`
+}
+
+// CreateJWTRole creates, or updates, a jwt auth role in Vault
+func (c *Client) CreateJWTRole(ctx context.Context, opts *CreateJWTRoleOptions) error {
+	return c.doRequest(ctx, http.MethodPost, jwtRolePath(opts.Mount, opts.Name), opts, nil)
+}
+
+// JWTRole is a jwt auth role returned by GetJWTRole
+type JWTRole struct {
+	RoleType            string                 `json:"role_type"`
+	UserClaim           string                 `json:"user_claim"`
+	BoundAudiences      []string               `json:"bound_audiences"`
+	BoundSubject        string                 `json:"bound_subject"`
+	BoundClaims         map[string]interface{} `json:"bound_claims"`
+	BoundClaimsType     string                 `json:"bound_claims_type"`
+	ClaimMappings       map[string]string      `json:"claim_mappings"`
+	AllowedRedirectURIs []string               `json:"allowed_redirect_uris"`
+	OIDCScopes          []string               `json:"oidc_scopes"`
+
+	// TokenTTL and TokenMaxTTL are in seconds
+	TokenTTL      int      `json:"token_ttl"`
+	TokenMaxTTL   int      `json:"token_max_ttl"`
+	TokenPolicies []string `json:"token_policies"`
+}
+
+// GetJWTRole returns a jwt auth role, an empty mount uses the default mount
+func (c *Client) GetJWTRole(ctx context.Context, mount, name string) (*JWTRole, error) {
+	var resp struct {
+		Data JWTRole `json:"data"`
+	}
+
+	if err := c.doRequest(ctx, http.MethodGet, jwtRolePath(mount, name), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// ListJWTRoles returns the names of the jwt auth roles, an empty mount uses
+// the default mount. If there are no roles nil is returned.
+func (c *Client) ListJWTRoles(ctx context.Context, mount string) ([]string, error) {
+	var resp struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	if err := c.doRequest(ctx, "LIST", path.Join("auth", authMount(mount, defaultJWTMount), "role"), nil, &resp); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return resp.Data.Keys, nil
+}
+
+// DeleteJWTRole deletes a jwt auth role, an empty mount uses the default
+// mount
+func (c *Client) DeleteJWTRole(ctx context.Context, mount, name string) error {
+	return c.doRequest(ctx, http.MethodDelete, jwtRolePath(mount, name), nil, nil)
+}
+
+// jwtRolePath returns the path of a jwt auth role
+func jwtRolePath(mount, name string) string {
+	return path.Join("auth", authMount(mount, defaultJWTMount), "role", name)
+}
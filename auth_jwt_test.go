@@ -0,0 +1,170 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJWTAuthMethod(t *testing.T) {
+	var logins []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/jwt/login" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`)) //nolint:errcheck // Why: test server
+			return
+		}
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck // Why: test server
+		logins = append(logins, body)
+		w.Write([]byte(`{"auth":{"client_token":"token","lease_duration":600}}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	jwtPath := filepath.Join(t.TempDir(), "jwt")
+	if err := os.WriteFile(jwtPath, []byte("file-jwt\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_JWT", "env-jwt")
+
+	sources := []*JWTAuthOptions{
+		{Role: "ci", Func: func(context.Context) (cfg.SecretData, error) { return "func-jwt", nil }},
+		{Role: "ci", Path: jwtPath},
+		{Env: "TEST_JWT"},
+	}
+
+	ctx := context.Background()
+	for _, opts := range sources {
+		am := NewJWTAuthMethod(opts)
+		am.Options(&Options{Host: srv.URL})
+
+		token, expiresAt, err := am.GetToken(ctx)
+		if err != nil {
+			t.Errorf("GetToken() = %v", err)
+			return
+		}
+
+		if token != "token" || expiresAt.IsZero() {
+			t.Errorf("GetToken(): expected an expiring token, got %q expiring at %v", token, expiresAt)
+		}
+	}
+
+	want := []map[string]string{
+		{"role": "ci", "jwt": "func-jwt"},
+		{"role": "ci", "jwt": "file-jwt"},
+		{"jwt": "env-jwt"},
+	}
+	if diff := cmp.Diff(want, logins); diff != "" {
+		t.Errorf("logins: %s", diff)
+	}
+
+	for _, opts := range []*JWTAuthOptions{{}, {Env: "TEST_JWT_MISSING"}} {
+		am := NewJWTAuthMethod(opts)
+		am.Options(&Options{Host: srv.URL})
+		if _, _, err := am.GetToken(ctx); err == nil {
+			t.Errorf("GetToken(): expected an error for %+v", opts)
+		}
+	}
+}
+
+func TestClient_JWTAdmin(t *testing.T) {
+	requests := make(map[string]map[string]interface{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`{"data":{"role_type":"jwt","user_claim":"sub","token_ttl":3600}}`)) //nolint:errcheck // Why: test server
+		case "LIST":
+			// Vault returns a 404 when there's nothing to list
+			if strings.HasPrefix(r.URL.Path, "/v1/auth/empty/") {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"errors":[]}`)) //nolint:errcheck // Why: test server
+				return
+			}
+			w.Write([]byte(`{"data":{"keys":["ci"]}}`)) //nolint:errcheck // Why: test server
+		default:
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck // Why: test server
+			requests[r.Method+" "+r.URL.Path] = body
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(WithAddress(srv.URL))
+
+	if err := c.ConfigureJWTAuth(ctx, &ConfigureJWTAuthOptions{
+		OIDCDiscoveryURL: "https://token.actions.githubusercontent.com",
+		BoundIssuer:      "https://token.actions.githubusercontent.com",
+	}); err != nil {
+		t.Errorf("ConfigureJWTAuth() = %v", err)
+		return
+	}
+
+	if err := c.CreateJWTRole(ctx, &CreateJWTRoleOptions{
+		Name:           "ci",
+		RoleType:       "jwt",
+		UserClaim:      "sub",
+		BoundAudiences: []string{"vault"},
+		BoundClaims:    map[string]interface{}{"repository": "getoutreach/vault-client"},
+	}); err != nil {
+		t.Errorf("CreateJWTRole() = %v", err)
+		return
+	}
+
+	role, err := c.GetJWTRole(ctx, "", "ci")
+	if err != nil {
+		t.Errorf("GetJWTRole() = %v", err)
+		return
+	}
+
+	if role.RoleType != "jwt" || role.UserClaim != "sub" || role.TokenTTL != 3600 {
+		t.Errorf("GetJWTRole(): unexpected role %+v", role)
+	}
+
+	roles, err := c.ListJWTRoles(ctx, "")
+	if err != nil {
+		t.Errorf("ListJWTRoles() = %v", err)
+		return
+	}
+
+	if diff := cmp.Diff([]string{"ci"}, roles); diff != "" {
+		t.Errorf("ListJWTRoles(): %s", diff)
+	}
+
+	if roles, err := c.ListJWTRoles(ctx, "empty"); err != nil || roles != nil {
+		t.Errorf("ListJWTRoles(): expected no roles for an empty mount, got %v, %v", roles, err)
+	}
+
+	if err := c.DeleteJWTRole(ctx, "", "ci"); err != nil {
+		t.Errorf("DeleteJWTRole() = %v", err)
+		return
+	}
+
+	want := map[string]map[string]interface{}{
+		"POST /v1/auth/jwt/config": {
+			"oidc_discovery_url": "https://token.actions.githubusercontent.com",
+			"bound_issuer":       "https://token.actions.githubusercontent.com",
+		},
+		"POST /v1/auth/jwt/role/ci": {
+			"role_type":       "jwt",
+			"user_claim":      "sub",
+			"bound_audiences": []interface{}{"vault"},
+			"bound_claims":    map[string]interface{}{"repository": "getoutreach/vault-client"},
+		},
+		"DELETE /v1/auth/jwt/role/ci": nil,
+	}
+	if diff := cmp.Diff(want, requests); diff != "" {
+		t.Errorf("requests: %s", diff)
+	}
+}
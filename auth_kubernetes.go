@@ -0,0 +1,141 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to interact with basic /auth/kubernetes endpoints
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
+)
+
+// DefaultKubernetesTokenPath is the path the service-account token is
+// mounted at in Kubernetes pods
+const DefaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// defaultKubernetesMount is the default mount of the kubernetes auth method
+const defaultKubernetesMount = "kubernetes"
+
+// KubernetesAuthOptions are options for a KubernetesAuthMethod
+type KubernetesAuthOptions struct {
+	// Role is the Vault role to login as
+	Role string
+
+	// Mount is the mount of the kubernetes auth method, defaults to
+	// kubernetes
+	Mount string
+
+	// TokenPath is the path of the service-account token, defaults to
+	// DefaultKubernetesTokenPath
+	TokenPath string
+}
+
+// KubernetesAuthMethod implements a AuthMethod backed by a Kubernetes
+// service-account token
+type KubernetesAuthMethod struct {
+	c *Client
+
+	role      string
+	mount     string
+	tokenPath string
+}
+
+// NewKubernetesAuthMethod returns a new KubernetesAuthMethod based on the
+// provided options.
+func NewKubernetesAuthMethod(opts *KubernetesAuthOptions) *KubernetesAuthMethod {
+	a := &KubernetesAuthMethod{
+		role:      opts.Role,
+		mount:     authMount(opts.Mount, defaultKubernetesMount),
+		tokenPath: opts.TokenPath,
+	}
+
+	if a.tokenPath == "" {
+		a.tokenPath = DefaultKubernetesTokenPath
+	}
+
+	return a
+}
+
+func (a *KubernetesAuthMethod) Options(o *Options) {
+	a.c = New(withInheritedOptions(o))
+}
+
+// GetToken returns a token for the current service-account. The
+// service-account token is read on every login since projected tokens are
+// rotated by the kubelet.
+func (a *KubernetesAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	b, err := os.ReadFile(a.tokenPath)
+	if err != nil {
+		return "", time.Time{}, errors.Wrapf(err, "failed to read kubernetes service-account token at '%s'", a.tokenPath)
+	}
+
+	auth, err := a.c.KubernetesLogin(ctx, a.mount, a.role, cfg.SecretData(strings.TrimSpace(string(b))))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, expiresAt := authToken(auth)
+	return token, expiresAt, nil
+}
+
+// KubernetesLogin creates a new token using the provided kubernetes auth
+// method mount, role and service-account token
+func (c *Client) KubernetesLogin(ctx context.Context, mount, role string, jwt cfg.SecretData) (*SecretAuth, error) {
+	return c.authLogin(ctx, path.Join("auth", mount, "login"), map[string]string{
+		"role": role,
+		"jwt":  string(jwt),
+	})
+}
+
+// ConfigureKubernetesAuthOptions are options to provide to
+// ConfigureKubernetesAuth, docs:
+// https://developer.hashicorp.com/vault/api-docs/auth/kubernetes#configure-method
+type ConfigureKubernetesAuthOptions struct {
+	// Mount is the mount of the kubernetes auth method, defaults to
+	// kubernetes
+	Mount string `json:"-"`
+
+	KubernetesHost       string   `json:"kubernetes_host"`
+	KubernetesCACert     string   `json:"kubernetes_ca_cert,omitempty"`
+	TokenReviewerJWT     string   `json:"token_reviewer_jwt,omitempty"`
+	PEMKeys              []string `json:"pem_keys,omitempty"`
+	Issuer               string   `json:"issuer,omitempty"`
+	DisableISSValidation bool     `json:"disable_iss_validation,omitempty"`
+	DisableLocalCAJWT    bool     `json:"disable_local_ca_jwt,omitempty"`
+}
+
+// ConfigureKubernetesAuth configures a kubernetes auth method in Vault
+func (c *Client) ConfigureKubernetesAuth(ctx context.Context, opts *ConfigureKubernetesAuthOptions) error {
+	return c.doRequest(ctx, http.MethodPost, path.Join("auth", authMount(opts.Mount, defaultKubernetesMount), "config"), opts, nil)
+}
+
+// CreateKubernetesRoleOptions are options to provide to
+// CreateKubernetesRole, docs:
+// https://developer.hashicorp.com/vault/api-docs/auth/kubernetes#create-role
+type CreateKubernetesRoleOptions struct {
+	// Name is the name of the role to create
+	Name string `json:"-"`
+
+	// Mount is the mount of the kubernetes auth method, defaults to
+	// kubernetes
+	Mount string `json:"-"`
+
+	BoundServiceAccountNames      []string `json:"bound_service_account_names"`
+	BoundServiceAccountNamespaces []string `json:"bound_service_account_namespaces"`
+	Audience                      string   `json:"audience,omitempty"`
+	TokenTTL                      string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL                   string   `json:"token_max_ttl,omitempty"`
+	TokenPolicies                 []string `json:"token_policies,omitempty"`
+	Period                        int      `json:"period,omitempty"`
+}
+
+// CreateKubernetesRole creates a new kubernetes auth role in Vault
+func (c *Client) CreateKubernetesRole(ctx context.Context, opts *CreateKubernetesRoleOptions) error {
+	return c.doRequest(ctx, http.MethodPost, path.Join("auth", authMount(opts.Mount, defaultKubernetesMount), "role", opts.Name), opts, nil)
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestKubernetesAuthMethod(t *testing.T) {
+	var logins []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/k8s/login" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`)) //nolint:errcheck // Why: test server
+			return
+		}
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck // Why: test server
+		logins = append(logins, body)
+		w.Write([]byte(`{"auth":{"client_token":"token","lease_duration":3600}}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("jwt-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	am := NewKubernetesAuthMethod(&KubernetesAuthOptions{Role: "app", Mount: "k8s", TokenPath: tokenPath})
+	am.Options(&Options{Host: srv.URL})
+
+	ctx := context.Background()
+	token, expiresAt, err := am.GetToken(ctx)
+	if err != nil {
+		t.Errorf("GetToken() = %v", err)
+		return
+	}
+
+	if token != "token" || expiresAt.IsZero() {
+		t.Errorf("GetToken(): expected an expiring token, got %q expiring at %v", token, expiresAt)
+	}
+
+	// the service-account token is rotated, it should be re-read on login
+	if err := os.WriteFile(tokenPath, []byte("jwt-2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := am.GetToken(ctx); err != nil {
+		t.Errorf("GetToken() = %v", err)
+		return
+	}
+
+	want := []map[string]string{{"role": "app", "jwt": "jwt-1"}, {"role": "app", "jwt": "jwt-2"}}
+	if diff := cmp.Diff(want, logins); diff != "" {
+		t.Errorf("logins: %s", diff)
+	}
+
+	missing := NewKubernetesAuthMethod(&KubernetesAuthOptions{Role: "app", TokenPath: filepath.Join(t.TempDir(), "missing")})
+	missing.Options(&Options{Host: srv.URL})
+	if _, _, err := missing.GetToken(ctx); err == nil {
+		t.Error("GetToken(): expected an error for a missing service-account token")
+	}
+}
+
+func TestClient_KubernetesAdmin(t *testing.T) {
+	requests := make(map[string]map[string]interface{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck // Why: test server
+		requests[r.URL.Path] = body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(WithAddress(srv.URL))
+
+	if err := c.ConfigureKubernetesAuth(ctx, &ConfigureKubernetesAuthOptions{KubernetesHost: "https://kubernetes.default.svc"}); err != nil {
+		t.Errorf("ConfigureKubernetesAuth() = %v", err)
+		return
+	}
+
+	if err := c.CreateKubernetesRole(ctx, &CreateKubernetesRoleOptions{
+		Name:                          "app",
+		Mount:                         "k8s",
+		BoundServiceAccountNames:      []string{"app"},
+		BoundServiceAccountNamespaces: []string{"default"},
+		TokenPolicies:                 []string{"app"},
+	}); err != nil {
+		t.Errorf("CreateKubernetesRole() = %v", err)
+		return
+	}
+
+	want := map[string]map[string]interface{}{
+		"/v1/auth/kubernetes/config": {"kubernetes_host": "https://kubernetes.default.svc"},
+		"/v1/auth/k8s/role/app": {
+			"bound_service_account_names":      []interface{}{"app"},
+			"bound_service_account_namespaces": []interface{}{"default"},
+			"token_policies":                   []interface{}{"app"},
+		},
+	}
+	if diff := cmp.Diff(want, requests); diff != "" {
+		t.Errorf("requests: %s", diff)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to interact with basic /auth/ldap endpoints
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+)
+
+// defaultLDAPMount is the default mount of the ldap auth method
+const defaultLDAPMount = "ldap"
+
+// LDAPAuthOptions are options for a LDAPAuthMethod. Either Password or
+// PasswordFunc should be set.
+type LDAPAuthOptions struct {
+	// Username is the LDAP user to login as
+	Username string
+
+	// Password is the LDAP password of the user
+	Password cfg.SecretData
+
+	// PasswordFunc returns the LDAP password of the user, it's called on
+	// every login and takes precedence over Password.
+	PasswordFunc PasswordFunc
+
+	// Mount is the mount of the ldap auth method, defaults to ldap
+	Mount string
+}
+
+// LDAPAuthMethod implements a AuthMethod backed by LDAP credentials
+type LDAPAuthMethod struct {
+	c *Client
+
+	username string
+	password PasswordFunc
+	mount    string
+}
+
+// NewLDAPAuthMethod returns a new LDAPAuthMethod based on the provided
+// options.
+func NewLDAPAuthMethod(opts *LDAPAuthOptions) *LDAPAuthMethod {
+	return &LDAPAuthMethod{
+		username: opts.Username,
+		password: passwordSource(opts.Password, opts.PasswordFunc),
+		mount:    authMount(opts.Mount, defaultLDAPMount),
+	}
+}
+
+func (a *LDAPAuthMethod) Options(o *Options) {
+	a.c = New(withInheritedOptions(o))
+}
+
+// GetToken returns a token for the LDAP user
+func (a *LDAPAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	return passwordLogin(ctx, a.c.LDAPLogin, a.mount, a.username, a.password)
+}
+
+// LDAPLogin creates a new token using the provided ldap auth method mount,
+// username and password
+func (c *Client) LDAPLogin(ctx context.Context, mount, username string, password cfg.SecretData) (*SecretAuth, error) {
+	return c.authLogin(ctx, path.Join("auth", mount, "login", username), map[string]string{
+		"password": string(password),
+	})
+}
@@ -0,0 +1,30 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLDAPAuthMethod(t *testing.T) {
+	f := newFakeUserpassVault(t, "corp-ldap")
+	f.users["alice"] = "hunter2"
+
+	ctx := context.Background()
+	c := New(WithAddress(f.URL), WithLDAPAuth(&LDAPAuthOptions{Username: "alice", Password: "hunter2", Mount: "corp-ldap"}))
+
+	token, expiresAt, err := c.opts.am.GetToken(ctx)
+	if err != nil {
+		t.Errorf("GetToken() = %v", err)
+		return
+	}
+
+	if token != "token-alice" || expiresAt.IsZero() {
+		t.Errorf("GetToken(): expected an expiring token-alice, got %q expiring at %v", token, expiresAt)
+	}
+
+	c = New(WithAddress(f.URL), WithLDAPAuth(&LDAPAuthOptions{Username: "alice", Password: "wrong", Mount: "corp-ldap"}))
+	if _, _, err := c.opts.am.GetToken(ctx); err == nil {
+		t.Error("GetToken(): expected an invalid password to be rejected")
+	}
+}
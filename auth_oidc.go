@@ -0,0 +1,87 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to interact with the /auth/oidc endpoints
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+// defaultOIDCMount is the default mount of the oidc auth method
+const defaultOIDCMount = "oidc"
+
+// OIDCAuthURL starts an OIDC login and returns the URL of the OIDC provider
+// the user has to log in at, the provider redirects to redirectURI once
+// that's done. The clientNonce has to be provided to OIDCCallback as well.
+// An empty mount uses the default mount and an empty role the mount's
+// default_role.
+func (c *Client) OIDCAuthURL(ctx context.Context, mount, role, redirectURI, clientNonce string) (string, error) {
+	var resp struct {
+		Data struct {
+			AuthURL string `json:"auth_url"`
+		} `json:"data"`
+	}
+
+	err := c.doRequest(ctx, http.MethodPost, path.Join("auth", authMount(mount, defaultOIDCMount), "oidc/auth_url"),
+		map[string]string{
+			"role":         role,
+			"redirect_uri": redirectURI,
+			"client_nonce": clientNonce,
+		}, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	// Vault returns an empty URL, rather than an error, when the redirect
+	// URI isn't allowed by the role
+	if resp.Data.AuthURL == "" {
+		return "", errors.Errorf("no auth url returned, is %q an allowed redirect uri of the role?", redirectURI)
+	}
+	return resp.Data.AuthURL, nil
+}
+
+// OIDCCallbackOptions are the parameters the OIDC provider redirected back
+// with, passed to OIDCCallback
+type OIDCCallbackOptions struct {
+	// Mount is the mount of the oidc auth method, defaults to oidc
+	Mount string
+
+	// State, Code and IDToken are the query parameters of the redirect
+	State   string
+	Code    string
+	IDToken string
+
+	// ClientNonce is the nonce provided to OIDCAuthURL
+	ClientNonce string
+}
+
+// OIDCCallback finishes an OIDC login started by OIDCAuthURL, returning the
+// token issued by Vault.
+func (c *Client) OIDCCallback(ctx context.Context, opts *OIDCCallbackOptions) (*SecretAuth, error) {
+	q := url.Values{}
+	q.Set("state", opts.State)
+	q.Set("code", opts.Code)
+	q.Set("client_nonce", opts.ClientNonce)
+	if opts.IDToken != "" {
+		q.Set("id_token", opts.IDToken)
+	}
+
+	// the callback is only available as a GET, so the parameters have to be
+	// sent in the query, which is kept out of traces, middleware and errors
+	endpoint := path.Join("auth", authMount(opts.Mount, defaultOIDCMount), "oidc/callback")
+
+	var sec Secret
+	if err := c.doRequestWithQuery(ctx, http.MethodGet, endpoint, q, nil, &sec); err != nil {
+		return nil, err
+	}
+
+	if sec.Auth == nil {
+		return nil, errors.New("no auth returned by oidc callback")
+	}
+	return sec.Auth, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_OIDC(t *testing.T) {
+	ctx := context.Background()
+
+	var authURLReq map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/sso/oidc/auth_url":
+			json.NewDecoder(r.Body).Decode(&authURLReq) //nolint:errcheck // Why: test server
+			authURL := ""
+			if authURLReq["redirect_uri"] == "http://localhost:8250/oidc/callback" {
+				authURL = "https://idp.example.com/authorize"
+			}
+			fmt.Fprintf(w, `{"data":{"auth_url":%q}}`, authURL)
+		case "/v1/auth/sso/oidc/callback":
+			q := r.URL.Query()
+			if r.Method != http.MethodGet || q.Get("state") != "st/ate" || q.Get("code") != "co&de" || q.Get("client_nonce") != "nonce" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"auth":{"client_token":"token","lease_duration":60}}`)) //nolint:errcheck // Why: test server
+		}
+	}))
+	defer srv.Close()
+
+	c := New(WithAddress(srv.URL))
+	authURL, err := c.OIDCAuthURL(ctx, "sso", "dev", "http://localhost:8250/oidc/callback", "nonce")
+	if err != nil {
+		t.Errorf("OIDCAuthURL() = %v", err)
+		return
+	}
+
+	if authURL != "https://idp.example.com/authorize" || authURLReq["role"] != "dev" || authURLReq["client_nonce"] != "nonce" {
+		t.Errorf("OIDCAuthURL(): unexpected url %q for request %v", authURL, authURLReq)
+	}
+
+	if _, err := c.OIDCAuthURL(ctx, "sso", "dev", "http://localhost:1234/oidc/callback", "nonce"); err == nil {
+		t.Error("OIDCAuthURL(): expected an error for a redirect uri that isn't allowed")
+	}
+
+	auth, err := c.OIDCCallback(ctx, &OIDCCallbackOptions{Mount: "sso", State: "st/ate", Code: "co&de", ClientNonce: "nonce"})
+	if err != nil {
+		t.Errorf("OIDCCallback() = %v", err)
+		return
+	}
+
+	if auth.ClientToken != "token" || auth.LeaseDuration != 60 {
+		t.Errorf("OIDCCallback(): unexpected auth %+v", auth)
+	}
+}
+
+func TestClient_OIDCCallbackQueryNotExposed(t *testing.T) {
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("code") != "secret-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	var seen []string
+	mw := func(next RequestHandler) RequestHandler {
+		return func(ctx context.Context, req *RequestInfo) error {
+			seen = append(seen, req.Endpoint)
+			return next(ctx, req)
+		}
+	}
+
+	c := New(WithAddress(srv.URL), WithMiddleware(mw))
+	_, err := c.OIDCCallback(ctx, &OIDCCallbackOptions{State: "state", Code: "secret-code", ClientNonce: "nonce"})
+	if !IsPermissionDenied(err) {
+		t.Errorf("OIDCCallback(): expected the query to be sent and the request denied, got %v", err)
+		return
+	}
+
+	if strings.Contains(err.Error(), "code=") {
+		t.Errorf("OIDCCallback(): error contains the query: %v", err)
+	}
+
+	if len(seen) != 1 || strings.Contains(seen[0], "code=") || seen[0] != "auth/oidc/oidc/callback" {
+		t.Errorf("OIDCCallback(): middleware saw the query: %v", seen)
+	}
+}
+
+func TestClient_OIDCCallbackQueryNotInTransportErrors(t *testing.T) {
+	// reset every connection, so the request fails in the transport
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	c := New(WithAddress(srv.URL), WithRetryPolicy(&RetryPolicy{
+		MaxAttempts:      2,
+		MinBackoff:       time.Millisecond,
+		MaxBackoff:       time.Millisecond,
+		RetryableMethods: []string{http.MethodGet},
+	}))
+	_, err := c.OIDCCallback(context.Background(), &OIDCCallbackOptions{State: "state", Code: "secret-code", ClientNonce: "nonce"})
+	if err == nil {
+		t.Error("OIDCCallback(): expected the request to fail")
+		return
+	}
+
+	if strings.Contains(err.Error(), "code=") || strings.Contains(err.Error(), "secret-code") {
+		t.Errorf("OIDCCallback(): error contains the query: %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "/v1/auth/oidc/oidc/callback") {
+		t.Errorf("OIDCCallback(): expected the error to contain the url without the query, got %v", err)
+	}
+}
@@ -5,10 +5,14 @@ package vault_client //nolint:revive // Why: We're using - in the name
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"path"
 	"time"
 
 	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
 )
 
 // TokenAuthMethod implements a AuthMethod backed by a static authentication token
@@ -29,27 +33,63 @@ func (a *TokenAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Ti
 func (*TokenAuthMethod) Options(*Options) {}
 
 // LookupTokenResponse is the response returned by LookupToken, docs:
-// https://www.vaultproject.io/api/auth/token#sample-response-2
+// https://developer.hashicorp.com/vault/api-docs/auth/token#lookup-a-token
+//
+// Vault returns some timestamps as unix seconds and others as RFC3339
+// strings, all of them are parsed into a time.Time. Timestamps Vault
+// didn't return, e.g. ExpireTime of a token without a TTL, are zero.
 type LookupTokenResponse struct {
-	Accessor         string    `json:"accessor"`
-	CreationTime     int       `json:"creation_time"`
-	CreationTTL      int       `json:"creation_ttl"`
-	DisplayName      string    `json:"display_name"`
-	EntityID         string    `json:"entity_id"`
-	ExpireTime       time.Time `json:"expire_time"`
-	ExplicitMaxTTL   int       `json:"explicit_max_ttl"`
-	ID               string    `json:"id"`
-	IdentityPolicies []string  `json:"identity_policies"`
-	IssueTime        string    `json:"issue_time"`
-	Meta             struct {
-		Username string `json:"username"`
-	} `json:"meta"`
-	NumUses   int      `json:"num_uses"`
-	Orphan    bool     `json:"orphan"`
-	Path      string   `json:"path"`
-	Policies  []string `json:"policies"`
-	Renewable bool     `json:"renewable"`
-	TTL       int      `json:"ttl"`
+	Accessor         string            `json:"accessor"`
+	BoundCIDRs       []string          `json:"bound_cidrs"`
+	CreationTime     time.Time         `json:"creation_time"`
+	CreationTTL      int               `json:"creation_ttl"`
+	DisplayName      string            `json:"display_name"`
+	EntityID         string            `json:"entity_id"`
+	ExpireTime       time.Time         `json:"expire_time"`
+	ExplicitMaxTTL   int               `json:"explicit_max_ttl"`
+	ID               string            `json:"id"`
+	IdentityPolicies []string          `json:"identity_policies"`
+	IssueTime        time.Time         `json:"issue_time"`
+	LastRenewalTime  time.Time         `json:"last_renewal_time"`
+	Meta             map[string]string `json:"meta"`
+	NumUses          int               `json:"num_uses"`
+	Orphan           bool              `json:"orphan"`
+	Path             string            `json:"path"`
+	Period           int               `json:"period"`
+	Policies         []string          `json:"policies"`
+	Renewable        bool              `json:"renewable"`
+	Role             string            `json:"role"`
+	TTL              int               `json:"ttl"`
+	Type             string            `json:"type"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, parsing the unix timestamps
+// returned by Vault.
+func (r *LookupTokenResponse) UnmarshalJSON(b []byte) error {
+	type lookupTokenResponse LookupTokenResponse
+	aux := struct {
+		*lookupTokenResponse
+
+		CreationTime    int64 `json:"creation_time"`
+		LastRenewalTime int64 `json:"last_renewal_time"`
+	}{lookupTokenResponse: (*lookupTokenResponse)(r)}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	r.CreationTime = unixTime(aux.CreationTime)
+	r.LastRenewalTime = unixTime(aux.LastRenewalTime)
+	return nil
+}
+
+// unixTime returns the time of the provided unix timestamp, or a zero
+// time.Time if it's not set.
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
 }
 
 // LookupToken looks up the provided token and returns information about it
@@ -82,3 +122,149 @@ func (c *Client) LookupCurrentToken(ctx context.Context) (*LookupTokenResponse,
 
 	return &resp.Data, nil
 }
+
+// tokenIncrement returns the body of a renew request for the given increment
+func tokenIncrement(body map[string]string, increment time.Duration) map[string]string {
+	if increment > 0 {
+		body["increment"] = fmt.Sprintf("%ds", int(increment.Seconds()))
+	}
+	return body
+}
+
+// RenewToken renews the provided token, requesting that its TTL be extended
+// by increment. If increment is 0 the token's default TTL is used.
+func (c *Client) RenewToken(ctx context.Context, token cfg.SecretData, increment time.Duration) (*SecretAuth, error) {
+	var resp Secret
+	err := c.doRequest(ctx, http.MethodPost, "auth/token/renew", tokenIncrement(map[string]string{
+		"token": string(token),
+	}, increment), &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Auth == nil {
+		return nil, errors.New("vault didn't return auth information")
+	}
+	return resp.Auth, nil
+}
+
+// RenewSelf renews the current active token (self), requesting that its TTL
+// be extended by increment. If increment is 0 the token's default TTL is used.
+func (c *Client) RenewSelf(ctx context.Context, increment time.Duration) (*SecretAuth, error) {
+	var resp Secret
+	err := c.doRequest(ctx, http.MethodPost, "auth/token/renew-self", tokenIncrement(map[string]string{}, increment), &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Auth == nil {
+		return nil, errors.New("vault didn't return auth information")
+	}
+	return resp.Auth, nil
+}
+
+// RevokeToken revokes the provided token and all of its children
+func (c *Client) RevokeToken(ctx context.Context, token cfg.SecretData) error {
+	return c.doRequest(ctx, http.MethodPost, "auth/token/revoke", map[string]string{
+		"token": string(token),
+	}, nil)
+}
+
+// RevokeSelf revokes the current active token (self) and all of its children
+func (c *Client) RevokeSelf(ctx context.Context) error {
+	return c.doRequest(ctx, http.MethodPost, "auth/token/revoke-self", nil, nil)
+}
+
+// CreateTokenOptions are options to provide to CreateToken, docs:
+// https://developer.hashicorp.com/vault/api-docs/auth/token#create-token
+type CreateTokenOptions struct {
+	// Role is the token role to create the token against, if set the
+	// role's settings take precedence over these options.
+	Role string `json:"-"`
+
+	// Orphan creates the token without a parent using create-orphan, so
+	// it isn't revoked with the token that created it. Orphan tokens of a
+	// role are configured on the role instead.
+	Orphan bool `json:"-"`
+
+	Policies        []string          `json:"policies,omitempty"`
+	Meta            map[string]string `json:"meta,omitempty"`
+	NoDefaultPolicy bool              `json:"no_default_policy,omitempty"`
+	Renewable       *bool             `json:"renewable,omitempty"`
+	TTL             string            `json:"ttl,omitempty"`
+	ExplicitMaxTTL  string            `json:"explicit_max_ttl,omitempty"`
+	Period          string            `json:"period,omitempty"`
+	NumUses         int               `json:"num_uses,omitempty"`
+	DisplayName     string            `json:"display_name,omitempty"`
+	EntityAlias     string            `json:"entity_alias,omitempty"`
+
+	// Type is the type of token to create, either service or batch
+	Type string `json:"type,omitempty"`
+}
+
+// CreateToken creates a new token, by default as a child of the current
+// active token (self).
+func (c *Client) CreateToken(ctx context.Context, opts *CreateTokenOptions) (*SecretAuth, error) {
+	endpoint := "auth/token/create"
+	switch {
+	case opts.Role != "" && opts.Orphan:
+		return nil, errors.New("orphan tokens of a role must be configured on the role")
+	case opts.Role != "":
+		endpoint = path.Join(endpoint, opts.Role)
+	case opts.Orphan:
+		endpoint = "auth/token/create-orphan"
+	}
+
+	var resp Secret
+	if err := c.doRequest(ctx, http.MethodPost, endpoint, opts, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Auth == nil {
+		return nil, errors.New("vault didn't return auth information")
+	}
+	return resp.Auth, nil
+}
+
+// LookupTokenAccessor looks up the token with the provided accessor and
+// returns information about it, the token's ID isn't returned.
+func (c *Client) LookupTokenAccessor(ctx context.Context, accessor string) (*LookupTokenResponse, error) {
+	var resp struct {
+		Data LookupTokenResponse
+	}
+	err := c.doRequest(ctx, http.MethodPost, "auth/token/lookup-accessor", map[string]string{
+		"accessor": accessor,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp.Data, nil
+}
+
+// RenewTokenAccessor renews the token with the provided accessor,
+// requesting that its TTL be extended by increment. If increment is 0 the
+// token's default TTL is used. The returned auth information doesn't
+// include the token.
+func (c *Client) RenewTokenAccessor(ctx context.Context, accessor string, increment time.Duration) (*SecretAuth, error) {
+	var resp Secret
+	err := c.doRequest(ctx, http.MethodPost, "auth/token/renew-accessor", tokenIncrement(map[string]string{
+		"accessor": accessor,
+	}, increment), &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Auth == nil {
+		return nil, errors.New("vault didn't return auth information")
+	}
+	return resp.Auth, nil
+}
+
+// RevokeTokenAccessor revokes the token with the provided accessor and all
+// of its children
+func (c *Client) RevokeTokenAccessor(ctx context.Context, accessor string) error {
+	return c.doRequest(ctx, http.MethodPost, "auth/token/revoke-accessor", map[string]string{
+		"accessor": accessor,
+	}, nil)
+}
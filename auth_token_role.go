@@ -0,0 +1,92 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to manage token roles
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"net/http"
+	"path"
+)
+
+// CreateTokenRoleOptions are options to provide to CreateTokenRole, docs:
+// https://developer.hashicorp.com/vault/api-docs/auth/token#create-update-token-role
+type CreateTokenRoleOptions struct {
+	// Name is the name of the token role to create or update
+	Name string `json:"-"`
+
+	AllowedPolicies        []string `json:"allowed_policies,omitempty"`
+	DisallowedPolicies     []string `json:"disallowed_policies,omitempty"`
+	AllowedPoliciesGlob    []string `json:"allowed_policies_glob,omitempty"`
+	DisallowedPoliciesGlob []string `json:"disallowed_policies_glob,omitempty"`
+	AllowedEntityAliases   []string `json:"allowed_entity_aliases,omitempty"`
+	Orphan                 bool     `json:"orphan,omitempty"`
+	Renewable              *bool    `json:"renewable,omitempty"`
+	PathSuffix             string   `json:"path_suffix,omitempty"`
+	TokenBoundCIDRs        []string `json:"token_bound_cidrs,omitempty"`
+	TokenExplicitMaxTTL    string   `json:"token_explicit_max_ttl,omitempty"`
+	TokenNoDefaultPolicy   bool     `json:"token_no_default_policy,omitempty"`
+	TokenNumUses           int      `json:"token_num_uses,omitempty"`
+	TokenPeriod            string   `json:"token_period,omitempty"`
+	TokenType              string   `json:"token_type,omitempty"`
+}
+
+// CreateTokenRole creates a new token role, or updates an existing one
+func (c *Client) CreateTokenRole(ctx context.Context, opts *CreateTokenRoleOptions) error {
+	return c.doRequest(ctx, http.MethodPost, path.Join("auth/token/roles", opts.Name), opts, nil)
+}
+
+// TokenRole is a token role returned by GetTokenRole, durations are in
+// seconds
+type TokenRole struct {
+	Name                   string   `json:"name"`
+	AllowedPolicies        []string `json:"allowed_policies"`
+	DisallowedPolicies     []string `json:"disallowed_policies"`
+	AllowedPoliciesGlob    []string `json:"allowed_policies_glob"`
+	DisallowedPoliciesGlob []string `json:"disallowed_policies_glob"`
+	AllowedEntityAliases   []string `json:"allowed_entity_aliases"`
+	Orphan                 bool     `json:"orphan"`
+	Renewable              bool     `json:"renewable"`
+	PathSuffix             string   `json:"path_suffix"`
+	TokenBoundCIDRs        []string `json:"token_bound_cidrs"`
+	TokenExplicitMaxTTL    int      `json:"token_explicit_max_ttl"`
+	TokenNoDefaultPolicy   bool     `json:"token_no_default_policy"`
+	TokenNumUses           int      `json:"token_num_uses"`
+	TokenPeriod            int      `json:"token_period"`
+	TokenType              string   `json:"token_type"`
+}
+
+// GetTokenRole returns a token role
+func (c *Client) GetTokenRole(ctx context.Context, name string) (*TokenRole, error) {
+	var resp struct {
+		Data TokenRole `json:"data"`
+	}
+
+	if err := c.doRequest(ctx, http.MethodGet, path.Join("auth/token/roles", name), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// ListTokenRoles returns the names of all token roles
+func (c *Client) ListTokenRoles(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	if err := c.doRequest(ctx, "LIST", "auth/token/roles", nil, &resp); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return resp.Data.Keys, nil
+}
+
+// DeleteTokenRole deletes a token role, tokens created against it aren't
+// revoked.
+func (c *Client) DeleteTokenRole(ctx context.Context, name string) error {
+	return c.doRequest(ctx, http.MethodDelete, path.Join("auth/token/roles", name), nil, nil)
+}
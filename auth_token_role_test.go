@@ -0,0 +1,75 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClient_TokenRoles(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+	renewable := false
+
+	if err := vc.CreateTokenRole(ctx, &CreateTokenRoleOptions{
+		Name:            "runner",
+		AllowedPolicies: []string{"default", "runner"},
+		Orphan:          true,
+		Renewable:       &renewable,
+		TokenPeriod:     "1h",
+	}); err != nil {
+		t.Errorf("CreateTokenRole() = %v", err)
+		return
+	}
+
+	role, err := vc.GetTokenRole(ctx, "runner")
+	if err != nil {
+		t.Errorf("GetTokenRole() = %v", err)
+		return
+	}
+
+	if role.Name != "runner" || !role.Orphan || role.Renewable || role.TokenPeriod != 3600 || len(role.AllowedPolicies) != 2 {
+		t.Errorf("GetTokenRole(): unexpected role %+v", role)
+	}
+
+	roles, err := vc.ListTokenRoles(ctx)
+	if err != nil {
+		t.Errorf("ListTokenRoles() = %v", err)
+		return
+	}
+
+	if len(roles) != 1 || roles[0] != "runner" {
+		t.Errorf("ListTokenRoles(): expected [runner], got %v", roles)
+	}
+
+	auth, err := vc.CreateToken(ctx, &CreateTokenOptions{Role: "runner", Policies: []string{"runner"}})
+	if err != nil {
+		t.Errorf("CreateToken() = %v", err)
+		return
+	}
+
+	info, err := vc.LookupToken(ctx, auth.ClientToken)
+	if err != nil {
+		t.Errorf("LookupToken() = %v", err)
+		return
+	}
+
+	if info.Role != "runner" || !info.Orphan || info.Renewable {
+		t.Errorf("LookupToken(): expected an orphan token of the runner role, got %+v", info)
+	}
+
+	if _, err := vc.CreateToken(ctx, &CreateTokenOptions{Role: "runner", Policies: []string{"admin"}}); err == nil {
+		t.Error("CreateToken(): expected an error for a policy not allowed by the role")
+	}
+
+	if err := vc.DeleteTokenRole(ctx, "runner"); err != nil {
+		t.Errorf("DeleteTokenRole() = %v", err)
+		return
+	}
+
+	if roles, err := vc.ListTokenRoles(ctx); err != nil || len(roles) != 0 {
+		t.Errorf("ListTokenRoles(): expected no roles, got %v, %v", roles, err)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+)
+
+func TestClient_RenewRevokeToken(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+
+	createToken := func() cfg.SecretData {
+		sec, err := vc.Write(ctx, "auth/token/create", map[string]interface{}{
+			"policies":         []string{"default"},
+			"ttl":              "1h",
+			"explicit_max_ttl": "3h",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create pre-req token: Write() = %v", err)
+		}
+		return sec.Auth.ClientToken
+	}
+
+	token := createToken()
+	auth, err := vc.RenewToken(ctx, token, 2*time.Hour)
+	if err != nil {
+		t.Errorf("Failed to renew token: RenewToken() = %v", err)
+		return
+	}
+
+	if auth.LeaseDuration != 7200 || !auth.Renewable {
+		t.Errorf("RenewToken(): expected a renewable lease of 7200s, got %+v", auth)
+	}
+
+	tokenClient := New(WithOptions(vc.opts), WithTokenAuth(token))
+	auth, err = tokenClient.RenewSelf(ctx, time.Hour)
+	if err != nil {
+		t.Errorf("Failed to renew token: RenewSelf() = %v", err)
+		return
+	}
+
+	if auth.LeaseDuration != 3600 {
+		t.Errorf("RenewSelf(): expected a lease of 3600s, got %+v", auth)
+	}
+
+	if err := vc.RevokeToken(ctx, token); err != nil {
+		t.Errorf("Failed to revoke token: RevokeToken() = %v", err)
+		return
+	}
+
+	if _, err := vc.LookupToken(ctx, token); err == nil {
+		t.Error("LookupToken(): expected revoked token lookup to fail")
+	}
+
+	token = createToken()
+	if err := New(WithOptions(vc.opts), WithTokenAuth(token)).RevokeSelf(ctx); err != nil {
+		t.Errorf("Failed to revoke token: RevokeSelf() = %v", err)
+		return
+	}
+
+	if _, err := vc.LookupToken(ctx, token); err == nil {
+		t.Error("LookupToken(): expected revoked token lookup to fail")
+	}
+}
+
+func TestClient_CreateToken(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+
+	child, err := vc.CreateToken(ctx, &CreateTokenOptions{
+		Policies: []string{"default"},
+		TTL:      "1h",
+		NumUses:  10,
+		Meta:     map[string]string{"job": "build"},
+	})
+	if err != nil {
+		t.Errorf("CreateToken() = %v", err)
+		return
+	}
+
+	info, err := vc.LookupToken(ctx, child.ClientToken)
+	if err != nil {
+		t.Errorf("LookupToken() = %v", err)
+		return
+	}
+
+	if info.Orphan || info.NumUses != 10 || info.Meta["job"] != "build" || info.CreationTTL != 3600 {
+		t.Errorf("LookupToken(): unexpected child token %+v", info)
+	}
+
+	if info.CreationTime.IsZero() || info.IssueTime.IsZero() || info.ExpireTime.Sub(info.IssueTime).Round(time.Second) != time.Hour {
+		t.Errorf("LookupToken(): expected timestamps to be parsed, got %+v", info)
+	}
+
+	orphan, err := vc.CreateToken(ctx, &CreateTokenOptions{Orphan: true, Period: "1h"})
+	if err != nil {
+		t.Errorf("CreateToken() = %v", err)
+		return
+	}
+
+	info, err = vc.LookupToken(ctx, orphan.ClientToken)
+	if err != nil {
+		t.Errorf("LookupToken() = %v", err)
+		return
+	}
+
+	if !info.Orphan || info.Period != 3600 {
+		t.Errorf("LookupToken(): expected a periodic orphan token, got %+v", info)
+	}
+
+	batch, err := vc.CreateToken(ctx, &CreateTokenOptions{Type: "batch", Policies: []string{"default"}, TTL: "10m"})
+	if err != nil {
+		t.Errorf("CreateToken() = %v", err)
+		return
+	}
+
+	if batch.TokenType != "batch" {
+		t.Errorf("CreateToken(): expected a batch token, got %+v", batch)
+	}
+
+	if _, err := vc.CreateToken(ctx, &CreateTokenOptions{Role: "role", Orphan: true}); err == nil {
+		t.Error("CreateToken(): expected an error for an orphan token of a role")
+	}
+}
+
+func TestClient_TokenAccessor(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+
+	auth, err := vc.CreateToken(ctx, &CreateTokenOptions{Policies: []string{"default"}, TTL: "1h", ExplicitMaxTTL: "3h"})
+	if err != nil {
+		t.Errorf("Failed to create pre-req token: CreateToken() = %v", err)
+		return
+	}
+
+	info, err := vc.LookupTokenAccessor(ctx, auth.Accessor)
+	if err != nil {
+		t.Errorf("LookupTokenAccessor() = %v", err)
+		return
+	}
+
+	if info.Accessor != auth.Accessor || info.ID != "" {
+		t.Errorf("LookupTokenAccessor(): expected the token without its ID, got %+v", info)
+	}
+
+	renewed, err := vc.RenewTokenAccessor(ctx, auth.Accessor, 2*time.Hour)
+	if err != nil {
+		t.Errorf("RenewTokenAccessor() = %v", err)
+		return
+	}
+
+	if renewed.LeaseDuration != 7200 {
+		t.Errorf("RenewTokenAccessor(): expected a lease of 7200s, got %+v", renewed)
+	}
+
+	info, err = vc.LookupTokenAccessor(ctx, auth.Accessor)
+	if err != nil {
+		t.Errorf("LookupTokenAccessor() = %v", err)
+		return
+	}
+
+	if info.LastRenewalTime.IsZero() {
+		t.Errorf("LookupTokenAccessor(): expected the last renewal time to be set, got %+v", info)
+	}
+
+	if err := vc.RevokeTokenAccessor(ctx, auth.Accessor); err != nil {
+		t.Errorf("RevokeTokenAccessor() = %v", err)
+		return
+	}
+
+	if _, err := vc.LookupToken(ctx, auth.ClientToken); err == nil {
+		t.Error("LookupToken(): expected revoked token lookup to fail")
+	}
+}
@@ -4,25 +4,88 @@
 package vault_client //nolint:revive // Why: We're using - in the name
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/getoutreach/gobox/pkg/log"
 	"github.com/getoutreach/gobox/pkg/trace"
 	"github.com/pkg/errors"
 )
 
+// maxRejectionBodySize is how much of the body of a 403 is read to decide if
+// Vault rejected the token
+const maxRejectionBodySize = 64 * 1024
+
+// refreshWindow is how long before a token expires it is refreshed, tokens
+// with a short TTL are refreshed once a third of their TTL remains instead.
+const refreshWindow = 5 * time.Minute
+
+// refreshTimeout is how long a background refresh of a token may take. It
+// isn't bound to the context of the request that started it, since other
+// requests may be waiting on it too.
+const refreshTimeout = time.Minute
+
+// refreshRetryInterval is how long requests keep using a still valid token,
+// without refreshing it, after a refresh failed.
+const refreshRetryInterval = 5 * time.Second
+
 // transport provides a http.RoundTripper by wrapping an existing
 // http.RoundTripper and provides Vault authentication.
 type transport struct {
 	tr http.RoundTripper
 	am AuthMethod
 
-	mu        sync.Mutex
-	token     cfg.SecretData
-	expiresAt time.Time
+	// opts are the options of the client that created this transport, they
+	// are used to renew tokens. If nil, tokens are never renewed.
+	opts *Options
+
+	mu sync.Mutex
+	tokenState
+
+	// refreshing is the in-flight refresh of the token, if any
+	refreshing *refreshCall
+
+	// retryAt is when the token may be refreshed again after a refresh
+	// failed, requests keep using the current token until then.
+	retryAt time.Time
+
+	// watchers are the running LifetimeWatchers of the token, they're
+	// stopped when the transport is closed.
+	watchers map[*LifetimeWatcher]struct{}
+
+	// closed is set once the transport was closed, no new tokens are
+	// obtained after that.
+	closed bool
+}
+
+// tokenState is a token held by a transport
+type tokenState struct {
+	token       cfg.SecretData
+	expiresAt   time.Time
+	refreshedAt time.Time
+
+	// ttl is the TTL the token was issued with
+	ttl time.Duration
+
+	// renewable denotes if the token should be renewed, rather than
+	// obtaining a new token from the AuthMethod, when it nears expiry.
+	renewable bool
+}
+
+// refreshCall is an in-flight refresh of a transport's token, it's shared
+// by every request that needs the token refreshed.
+type refreshCall struct {
+	done chan struct{}
+
+	// renewed and err are set before done is closed
+	renewed bool
+	err     error
 }
 
 // New returns a Transport that automatically refreshes Vault authentication
@@ -37,48 +100,425 @@ func NewTransport(tr http.RoundTripper, am AuthMethod) http.RoundTripper {
 	return &transport{tr: tr, am: am}
 }
 
-// RoundTrip implements http.RoundTripper interface.
+// newTransport returns a transport for a client created with opts, which is
+// able to renew tokens.
+func newTransport(opts *Options) *transport {
+	return &transport{tr: opts.tr, am: opts.am, opts: opts}
+}
+
+// RoundTrip implements http.RoundTripper interface. If Vault rejects the
+// token with a 403 and looking the token up confirms it's no longer valid,
+// e.g. because it was revoked early, the token is thrown away and the
+// request is replayed once with a new token. Requests denied by policy are
+// returned as is.
 func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	token, err := t.Token(req.Context())
+	ctx := req.Context()
+
+	token, err := t.Token(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	resp, err := t.tr.RoundTrip(authorize(req, token))
+	if err != nil || resp.StatusCode != http.StatusForbidden || token == "" {
+		return resp, err
+	}
+
+	// we can only replay requests whose body can be read again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	if !t.rejectsToken(resp) {
+		return resp, nil
+	}
+
+	newToken, ok := t.reauthenticate(req, token)
+	if !ok {
+		return resp, nil
+	}
+
+	replay := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		replay.Body = body
+	}
+
+	// drain the body so the connection can be reused
+	io.Copy(io.Discard, resp.Body) //nolint:errcheck // Why: best effort
+	resp.Body.Close()
+
+	trace.AddInfo(ctx, log.F{"vault.reauthenticated": true})
+	return t.tr.RoundTrip(authorize(replay, newToken))
+}
+
+// authorize adds the token to the request, if there is one
+func authorize(req *http.Request, token cfg.SecretData) *http.Request {
 	if token != "" {
 		req.Header.Set("Authorization", "Bearer "+string(token))
 	}
-	resp, err := t.tr.RoundTrip(req)
-	return resp, err
+	return req
+}
+
+// rejectsToken returns true if the body of the 403 resp is the error Vault
+// returns for invalid tokens, which it also returns for requests denied by
+// policy. The body is left intact for the caller.
+func (t *transport) rejectsToken(resp *http.Response) bool {
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxRejectionBodySize))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), resp.Body), resp.Body}
+	if err != nil {
+		return false
+	}
+
+	body := string(b)
+	return strings.Contains(body, "permission denied") || strings.Contains(body, "invalid token")
+}
+
+// reauthenticate throws away the provided token, which Vault rejected when
+// sending req, and returns a new token if one could be obtained. It returns
+// false if there's no point in replaying the request, e.g. because the token
+// is still valid and the request was denied by policy. The rejected token is
+// revoked, on a best effort basis, once it was replaced.
+func (t *transport) reauthenticate(req *http.Request, rejected cfg.SecretData) (cfg.SecretData, bool) {
+	ctx := req.Context()
+
+	// a token provided by the caller can't be replaced
+	if _, ok := t.am.(*TokenAuthMethod); ok || t.am == nil {
+		return "", false
+	}
+
+	if token, done, ok := t.replacedToken(rejected); done {
+		return token, ok
+	}
+
+	// Vault returns the same error for invalid tokens and requests denied
+	// by policy, so look the token up to tell them apart
+	if _, err := t.tokenClient(req, rejected).LookupCurrentToken(ctx); !IsPermissionDenied(err) {
+		return "", false
+	}
+
+	t.mu.Lock()
+	if token, done, ok := t.replacedTokenLocked(rejected); done {
+		t.mu.Unlock()
+		return token, ok
+	}
+
+	// only the request that starts the refresh revokes the rejected token
+	started := t.refreshing == nil
+	call := t.startRefresh(ctx, true)
+	t.mu.Unlock()
+
+	if err := call.wait(ctx); err != nil {
+		return "", false
+	}
+
+	t.mu.Lock()
+	token := t.token
+	t.mu.Unlock()
+
+	if token == "" || token == rejected {
+		return "", false
+	}
+
+	if started && !isStaticAuthMethod(t.am) {
+		err := t.tokenClient(req, rejected).RevokeSelf(ctx)
+		if err != nil {
+			trace.AddInfo(ctx, log.F{"vault.revoke_error": err.Error()})
+		}
+	}
+	return token, true
 }
 
-// Token checks the active token expiration and renews if necessary. Token returns
-// a valid client token. If renewal fails an error is returned.
+// replacedToken see replacedTokenLocked
+func (t *transport) replacedToken(rejected cfg.SecretData) (token cfg.SecretData, done, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.replacedTokenLocked(rejected)
+}
+
+// replacedTokenLocked returns true as done if reauthenticate needn't obtain
+// a new token for the rejected token, either because another request already
+// replaced it, in which case the new token is returned with ok set, or
+// because no new token may be obtained right now. t.mu must be held.
+func (t *transport) replacedTokenLocked(rejected cfg.SecretData) (token cfg.SecretData, done, ok bool) {
+	if t.token != rejected {
+		return t.token, true, t.token != ""
+	}
+
+	if t.closed || time.Now().Before(t.retryAt) {
+		return "", true, false
+	}
+	return "", false, false
+}
+
+// tokenClient returns a client that authenticates with token and talks to
+// the Vault server req was sent to, it's used to look up and revoke tokens.
+func (t *transport) tokenClient(req *http.Request, token cfg.SecretData) *Client {
+	opts := t.opts
+	if opts == nil {
+		opts = &Options{
+			Host:      req.URL.Scheme + "://" + req.URL.Host,
+			Namespace: req.Header.Get("X-Vault-Namespace"),
+			tr:        t.tr,
+		}
+	}
+	return New(withInheritedOptions(opts), WithTokenAuth(token))
+}
+
+// Token returns a valid client token. If the token nears its expiration it's
+// refreshed in the background while the current token continues to be used,
+// requests only wait for a refresh, until ctx is done, if there's no valid
+// token. If the refresh fails an error is returned.
 func (t *transport) Token(ctx context.Context) (cfg.SecretData, error) {
+	if t.am == nil {
+		return "", nil
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return "", errors.New("vault client is closed")
+	}
+
+	if !t.needsRefresh() {
+		defer t.mu.Unlock()
+		return t.token, nil
+	}
+
+	if t.usable() {
+		if !time.Now().Before(t.retryAt) {
+			t.startRefresh(ctx, false)
+		}
+
+		defer t.mu.Unlock()
+		return t.token, nil
+	}
+
+	call := t.startRefresh(ctx, false)
+	t.mu.Unlock()
+
+	if err := call.wait(ctx); err != nil {
+		return "", errors.Wrap(err, "failed to refresh vault token")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.token, nil
+}
+
+// startRefresh starts refreshing the token in the background, unless a
+// refresh is already in-flight, and returns the refresh. If login is true a
+// new token is obtained from the AuthMethod instead of renewing the current
+// one. t.mu must be held.
+func (t *transport) startRefresh(ctx context.Context, login bool) *refreshCall {
+	if t.refreshing != nil {
+		return t.refreshing
+	}
+
+	call := &refreshCall{done: make(chan struct{})}
+	t.refreshing = call
+
+	s := t.tokenState
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+	go func() {
+		defer cancel()
+		t.refresh(ctx, s, login, call)
+	}()
+
+	return call
+}
+
+// refresh refreshes the token s and stores the result, keeping s if it's
+// still valid and the refresh failed.
+func (t *transport) refresh(ctx context.Context, s tokenState, login bool, call *refreshCall) {
 	ctx = trace.StartCall(ctx, "vault.token_refresh")
 	defer trace.EndCall(ctx)
 
+	if login {
+		s, call.err = t.login(ctx, s)
+	} else {
+		s, call.renewed, call.err = t.refreshToken(ctx, s)
+	}
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// if the token is empty, we always want to refresh, otherwise if we have
-	// an expiresAt, we want to check if it's within 5 minutes of now. if so,
-	// we want to refresh it
-	if t.token == "" || (!t.expiresAt.IsZero() && t.expiresAt.Add(-(time.Minute * 5)).Before(time.Now())) {
-		// Token is not set or expired/nearly expired, so refresh
-		if err := t.refreshToken(ctx); err != nil {
-			return "", errors.Wrap(err, "failed to refresh vault approle")
+	switch {
+	case call.err == nil:
+		t.tokenState, t.retryAt = s, time.Time{}
+	case s.usable():
+		t.tokenState, t.retryAt = s, time.Now().Add(refreshRetryInterval)
+	default:
+		t.tokenState, t.retryAt = tokenState{}, time.Now().Add(refreshRetryInterval)
+	}
+
+	t.refreshing = nil
+	close(call.done)
+}
+
+// wait waits for the refresh to complete and returns its error, or the
+// error of ctx if it's done first.
+func (c *refreshCall) wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// usable returns true if the token is set and not expired
+func (s *tokenState) usable() bool {
+	return s.token != "" && (s.expiresAt.IsZero() || time.Now().Before(s.expiresAt))
+}
+
+// needsRefresh returns true if the token is not set or nearly expired
+func (s *tokenState) needsRefresh() bool {
+	return s.token == "" || (!s.expiresAt.IsZero() && !time.Now().Before(s.refreshAt()))
+}
+
+// refreshAt returns when the token should be refreshed
+func (s *tokenState) refreshAt() time.Time {
+	window := refreshWindow
+	if s.ttl > 0 && s.ttl/3 < window {
+		window = s.ttl / 3
+	}
+	return s.expiresAt.Add(-window)
+}
+
+// refreshToken renews s if it's renewable, falling back to obtaining a new
+// token from the AuthMethod. It returns true if the token was renewed.
+func (t *transport) refreshToken(ctx context.Context, s tokenState) (tokenState, bool, error) {
+	if s.renewable && s.usable() {
+		renewed, err := t.renewToken(ctx, s)
+		if err == nil {
+			return renewed, true, nil
 		}
+		trace.AddInfo(ctx, log.F{"vault.renew_error": err.Error()})
+		s = renewed
 	}
 
-	return t.token, nil
+	s, err := t.login(ctx, s)
+	return s, false, err
 }
 
-func (t *transport) refreshToken(ctx context.Context) error {
-	if t.am == nil {
+// login obtains a new token from the AuthMethod, s is returned if that
+// fails.
+func (t *transport) login(ctx context.Context, s tokenState) (tokenState, error) {
+	token, expiresAt, err := t.am.GetToken(ctx)
+	if err != nil {
+		return s, err
+	}
+
+	ns := tokenState{token: token, expiresAt: expiresAt, refreshedAt: time.Now()}
+	if !expiresAt.IsZero() {
+		ns.ttl = expiresAt.Sub(ns.refreshedAt)
+	}
+
+	// tokens without an expiration don't need to be renewed
+	ns.renewable = t.opts != nil && ns.ttl > 0
+	return ns, nil
+}
+
+// renewToken renews s using renew-self. If the renewal didn't extend the
+// token's TTL, e.g. because the max TTL was reached, an error is returned
+// and the token won't be renewed again.
+func (t *transport) renewToken(ctx context.Context, s tokenState) (tokenState, error) {
+	c := New(withInheritedOptions(t.opts), WithTokenAuth(s.token))
+	auth, err := c.RenewSelf(ctx, s.ttl)
+	if err != nil {
+		s.renewable = false
+		return s, err
+	}
+
+	ttl := time.Duration(auth.LeaseDuration) * time.Second
+	if ttl <= 0 || !auth.Renewable {
+		s.renewable = false
+	}
+
+	// Vault caps the TTL at the token's max TTL, once that happens the
+	// token can't be meaningfully renewed any more.
+	if ttl < s.ttl {
+		s.renewable = false
+	}
+
+	s.expiresAt = time.Now().Add(ttl)
+	if s.needsRefresh() {
+		return s, errors.New("token is nearing its max ttl")
+	}
+
+	trace.AddInfo(ctx, log.F{"vault.token_renewed": true})
+	return s, nil
+}
+
+// CloseIdleConnections closes the idle connections of the underlying
+// http.RoundTripper, if it supports it.
+func (t *transport) CloseIdleConnections() {
+	if tr, ok := t.tr.(interface{ CloseIdleConnections() }); ok {
+		tr.CloseIdleConnections()
+	}
+}
+
+// close stops the token's LifetimeWatchers, waits for an in-flight refresh
+// and revokes the token. Tokens provided by the caller are only revoked if
+// revokeStatic is true. Once closed no new tokens are obtained.
+func (t *transport) close(ctx context.Context, revokeStatic bool) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
 		return nil
 	}
+	t.closed = true
+
+	watchers := t.watchers
+	t.watchers = nil
+	t.mu.Unlock()
+
+	for w := range watchers {
+		w.Stop()
+	}
 
-	var err error
-	t.token, t.expiresAt, err = t.am.GetToken(ctx)
-	return err
+	t.mu.Lock()
+	call := t.refreshing
+	t.mu.Unlock()
+
+	// the refresh may obtain a new token that has to be revoked too, if
+	// waiting for it fails we revoke the current token instead.
+	if call != nil {
+		call.wait(ctx) //nolint:errcheck // Why: best effort
+	}
+
+	t.mu.Lock()
+	token := t.token
+	t.tokenState = tokenState{}
+	t.mu.Unlock()
+
+	if token == "" || t.opts == nil || (isStaticAuthMethod(t.am) && !revokeStatic) {
+		return nil
+	}
+
+	ctx = trace.StartCall(ctx, "vault.token_revoke")
+	defer trace.EndCall(ctx)
+
+	c := New(withInheritedOptions(t.opts), WithTokenAuth(token))
+	return errors.Wrap(c.RevokeSelf(ctx), "failed to revoke vault token")
+}
+
+// isStaticAuthMethod returns true if am provides a token that was given to
+// the client, rather than obtained by logging in.
+func isStaticAuthMethod(am AuthMethod) bool {
+	switch am := am.(type) {
+	case *TokenAuthMethod, *TokenFileAuthMethod, *TokenHelperAuthMethod:
+		return true
+	case *ChainAuthMethod:
+		return isStaticAuthMethod(am.Active())
+	default:
+		return false
+	}
 }
@@ -0,0 +1,397 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fakeApproleVault is a fake Vault server that issues a new token on every
+// approle login and only accepts tokens that weren't revoked.
+type fakeApproleVault struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	logins      int
+	renewals    int
+	revocations int
+	revoked     map[string]bool
+
+	// denied denies every request that doesn't manage the token itself,
+	// as if it was denied by policy
+	denied bool
+
+	// slow, if set, delays logins and renewals until it's closed
+	slow chan struct{}
+
+	// loginLease and renewLease are the lease durations, in seconds,
+	// returned by logins and renewals
+	loginLease int
+	renewLease int
+}
+
+// newFakeApproleVault starts a new fakeApproleVault
+func newFakeApproleVault(t *testing.T) *fakeApproleVault {
+	t.Helper()
+
+	f := &fakeApproleVault{revoked: make(map[string]bool), loginLease: 3600, renewLease: 3600}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// handle implements http.HandlerFunc
+func (f *fakeApproleVault) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/auth/approle/login" || r.URL.Path == "/v1/auth/token/renew-self" {
+		f.mu.Lock()
+		slow := f.slow
+		f.mu.Unlock()
+
+		if slow != nil {
+			<-slow
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		f.logins++
+		fmt.Fprintf(w, `{"auth":{"client_token":"token-%d","lease_duration":%d,"renewable":true}}`, f.logins, f.loginLease)
+		return
+	}
+
+	if r.URL.Path == "/v1/auth/token/revoke-self" {
+		f.revocations++
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	policyDenied := f.denied && !strings.HasPrefix(r.URL.Path, "/v1/auth/token/")
+	if policyDenied || f.revoked[token] {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`)) //nolint:errcheck // Why: test server
+		return
+	}
+
+	if r.URL.Path == "/v1/auth/token/revoke-self" {
+		f.revoked[token] = true
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.URL.Path == "/v1/auth/token/renew-self" {
+		f.renewals++
+		fmt.Fprintf(w, `{"auth":{"client_token":%q,"lease_duration":%d,"renewable":true}}`, token, f.renewLease)
+		return
+	}
+
+	b, _ := io.ReadAll(r.Body) //nolint:errcheck // Why: test server
+	fmt.Fprintf(w, `{"data":{"id":%q,"plaintext":%q}}`, token, base64.StdEncoding.EncodeToString(b))
+}
+
+// revoke revokes the provided token
+func (f *fakeApproleVault) revoke(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revoked[token] = true
+}
+
+// isRevoked returns true if the provided token was revoked
+func (f *fakeApproleVault) isRevoked(token string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.revoked[token]
+}
+
+// loginCount returns the number of approle logins
+func (f *fakeApproleVault) loginCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logins
+}
+
+// setSlow delays logins and renewals until slow is closed
+func (f *fakeApproleVault) setSlow(slow chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.slow = slow
+}
+
+// renewCount returns the number of token renewals
+func (f *fakeApproleVault) renewCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.renewals
+}
+
+// expireToken makes the client's current token due for a refresh
+func expireToken(c *Client) {
+	t := c.hc.Transport.(*transport)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expiresAt = time.Now().Add(time.Minute)
+}
+
+// waitForRefresh waits for the background refresh of the client's token,
+// if there is one, to complete
+func waitForRefresh(c *Client) {
+	t := c.hc.Transport.(*transport)
+	t.mu.Lock()
+	call := t.refreshing
+	t.mu.Unlock()
+
+	if call != nil {
+		<-call.done
+	}
+}
+
+// revokeCount returns the number of revoke-self requests
+func (f *fakeApproleVault) revokeCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.revocations
+}
+
+func TestTransport_Reauthenticate(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeApproleVault(t)
+	c := New(WithAddress(f.URL), WithApproleAuth("role-id", "secret-id"))
+
+	info, err := c.LookupCurrentToken(ctx)
+	if err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	if info.ID != "token-1" {
+		t.Errorf("expected token-1 to be used, got %q", info.ID)
+	}
+
+	// revoke the token early, the request should be replayed (including
+	// the body) with a new token
+	f.revoke("token-1")
+
+	// the fake server echos the request body as the plaintext
+	plaintext, err := c.TransitDecrypt(ctx, "key", []byte("vault:v1:abc"))
+	if err != nil {
+		t.Errorf("TransitDecrypt(): expected request to be replayed with a new token, got %v", err)
+		return
+	}
+
+	if string(plaintext) != `{"ciphertext":"vault:v1:abc"}` {
+		t.Errorf("TransitDecrypt(): expected request body to be replayed, got %q", plaintext)
+	}
+
+	if f.loginCount() != 2 {
+		t.Errorf("expected a single re-authentication, got %d logins", f.loginCount())
+	}
+
+	if f.revokeCount() != 1 {
+		t.Errorf("expected the replaced token to be revoked, got %d revocations", f.revokeCount())
+	}
+
+	info, err = c.LookupCurrentToken(ctx)
+	if err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	if info.ID != "token-2" {
+		t.Errorf("expected token-2 to be used after re-authentication, got %q", info.ID)
+	}
+}
+
+func TestTransport_ReauthenticateGuards(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeApproleVault(t)
+	f.denied = true
+
+	c := New(WithAddress(f.URL), WithApproleAuth("role-id", "secret-id"))
+
+	// a request denied by policy, with a token that's still valid,
+	// shouldn't cause a re-authentication no matter how often it happens
+	for i := 0; i < 3; i++ {
+		if _, err := c.TransitDecrypt(ctx, "key", []byte("vault:v1:abc")); !IsPermissionDenied(err) {
+			t.Errorf("TransitDecrypt(): expected permission denied, got %v", err)
+		}
+	}
+
+	if f.loginCount() != 1 {
+		t.Errorf("expected no re-authentication for a valid token, got %d logins", f.loginCount())
+	}
+
+	// once the token is invalid we re-authenticate once, but don't loop
+	// if the request is denied with the new token too
+	f.revoke("token-1")
+	if _, err := c.TransitDecrypt(ctx, "key", []byte("vault:v1:abc")); !IsPermissionDenied(err) {
+		t.Errorf("TransitDecrypt(): expected permission denied, got %v", err)
+	}
+
+	if f.loginCount() != 2 {
+		t.Errorf("expected a single re-authentication, got %d logins", f.loginCount())
+	}
+
+	// static tokens can't be refreshed, so the request isn't replayed and
+	// the token isn't revoked
+	f.revoke("static")
+	static := New(WithAddress(f.URL), WithTokenAuth("static"))
+	if _, err := static.LookupCurrentToken(ctx); !IsPermissionDenied(err) {
+		t.Errorf("LookupCurrentToken(): expected permission denied, got %v", err)
+	}
+
+	if f.revokeCount() != 1 {
+		t.Errorf("expected only the replaced token-1 to be revoked, got %d revocations", f.revokeCount())
+	}
+}
+
+func TestTransport_Renew(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeApproleVault(t)
+	c := New(WithAddress(f.URL), WithApproleAuth("role-id", "secret-id"))
+
+	if _, err := c.LookupCurrentToken(ctx); err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	// a token nearing expiry should be renewed instead of logging in again
+	expireToken(c)
+	if _, err := c.LookupCurrentToken(ctx); err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+	waitForRefresh(c)
+
+	if f.loginCount() != 1 || f.renewCount() != 1 {
+		t.Errorf("expected 1 login and 1 renewal, got %d logins and %d renewals", f.loginCount(), f.renewCount())
+	}
+
+	// once the max ttl caps the renewal we should fall back to logging in
+	f.renewLease = 60
+	expireToken(c)
+	if _, err := c.LookupCurrentToken(ctx); err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+	waitForRefresh(c)
+
+	info, err := c.LookupCurrentToken(ctx)
+	if err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	if f.loginCount() != 2 || f.renewCount() != 2 {
+		t.Errorf("expected 2 logins and 2 renewals, got %d logins and %d renewals", f.loginCount(), f.renewCount())
+	}
+
+	if info.ID != "token-2" {
+		t.Errorf("expected a new token after reaching the max ttl, got %q", info.ID)
+	}
+}
+
+func TestTransport_RefreshInBackground(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeApproleVault(t)
+	c := New(WithAddress(f.URL), WithApproleAuth("role-id", "secret-id"))
+
+	if _, err := c.LookupCurrentToken(ctx); err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	slow := make(chan struct{})
+	f.setSlow(slow)
+	expireToken(c)
+
+	// requests keep using the still valid token while it's being refreshed
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+
+			info, err := c.LookupCurrentToken(ctx)
+			if err != nil {
+				t.Errorf("LookupCurrentToken(): expected the current token to be used, got %v", err)
+				return
+			}
+
+			if info.ID != "token-1" {
+				t.Errorf("expected token-1 to be used, got %q", info.ID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// requests without a token wait for the login, until their context is done
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	fresh := New(WithAddress(f.URL), WithApproleAuth("role-id", "secret-id"))
+	if _, err := fresh.LookupCurrentToken(tctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LookupCurrentToken(): expected deadline exceeded, got %v", err)
+	}
+
+	close(slow)
+	for deadline := time.Now().Add(5 * time.Second); f.renewCount() == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if f.renewCount() != 1 {
+		t.Errorf("expected a single background renewal, got %d", f.renewCount())
+	}
+}
+
+func TestClient_WatchTokenLifetime(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeApproleVault(t)
+	f.loginLease = 1
+	f.renewLease = 1
+
+	c := New(WithAddress(f.URL), WithApproleAuth("role-id", "secret-id"))
+	w, err := c.WatchTokenLifetime(ctx)
+	if err != nil {
+		t.Errorf("WatchTokenLifetime() = %v", err)
+		return
+	}
+
+	var got []LifetimeEventType
+	timeout := time.After(10 * time.Second)
+	for len(got) < 2 {
+		select {
+		case ev := <-w.Events():
+			if ev.Err != nil || ev.ExpiresAt.IsZero() {
+				t.Errorf("unexpected event: %+v", ev)
+			}
+			got = append(got, ev.Type)
+		case <-timeout:
+			t.Fatalf("timed out waiting for events, got %v", got)
+		}
+	}
+	w.Stop()
+
+	if got[0] != LifetimeEventReauthenticated || got[1] != LifetimeEventRenewed {
+		t.Errorf("expected a login followed by a renewal, got %v", got)
+	}
+
+	if _, ok := <-w.Events(); ok {
+		t.Error("expected events channel to be closed once stopped")
+	}
+
+	if _, err := New().WatchTokenLifetime(ctx); err == nil {
+		t.Error("WatchTokenLifetime(): expected an error for a client without an auth method")
+	}
+}
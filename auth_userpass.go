@@ -0,0 +1,165 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to interact with basic /auth/userpass endpoints
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
+)
+
+// defaultUserpassMount is the default mount of the userpass auth method
+const defaultUserpassMount = "userpass"
+
+// PasswordFunc returns the password to login with, e.g. by prompting for it
+type PasswordFunc func(ctx context.Context) (cfg.SecretData, error)
+
+// UserpassAuthOptions are options for a UserpassAuthMethod. Either Password
+// or PasswordFunc should be set.
+type UserpassAuthOptions struct {
+	// Username is the user to login as
+	Username string
+
+	// Password is the password of the user
+	Password cfg.SecretData
+
+	// PasswordFunc returns the password of the user, it's called on every
+	// login and takes precedence over Password.
+	PasswordFunc PasswordFunc
+
+	// Mount is the mount of the userpass auth method, defaults to userpass
+	Mount string
+}
+
+// UserpassAuthMethod implements a AuthMethod backed by a username and
+// password
+type UserpassAuthMethod struct {
+	c *Client
+
+	username string
+	password PasswordFunc
+	mount    string
+}
+
+// NewUserpassAuthMethod returns a new UserpassAuthMethod based on the
+// provided options.
+func NewUserpassAuthMethod(opts *UserpassAuthOptions) *UserpassAuthMethod {
+	return &UserpassAuthMethod{
+		username: opts.Username,
+		password: passwordSource(opts.Password, opts.PasswordFunc),
+		mount:    authMount(opts.Mount, defaultUserpassMount),
+	}
+}
+
+func (a *UserpassAuthMethod) Options(o *Options) {
+	a.c = New(withInheritedOptions(o))
+}
+
+// GetToken returns a token for the user
+func (a *UserpassAuthMethod) GetToken(ctx context.Context) (cfg.SecretData, time.Time, error) {
+	return passwordLogin(ctx, a.c.UserpassLogin, a.mount, a.username, a.password)
+}
+
+// UserpassLogin creates a new token using the provided userpass auth method
+// mount, username and password
+func (c *Client) UserpassLogin(ctx context.Context, mount, username string, password cfg.SecretData) (*SecretAuth, error) {
+	return c.authLogin(ctx, path.Join("auth", mount, "login", username), map[string]string{
+		"password": string(password),
+	})
+}
+
+// passwordSource returns fn, or a PasswordFunc returning password if fn is
+// nil
+func passwordSource(password cfg.SecretData, fn PasswordFunc) PasswordFunc {
+	if fn != nil {
+		return fn
+	}
+
+	return func(context.Context) (cfg.SecretData, error) {
+		if password == "" {
+			return "", errors.New("no password configured")
+		}
+		return password, nil
+	}
+}
+
+// passwordLogin logs in as username with the password returned by password
+// using login, e.g. Client.UserpassLogin
+func passwordLogin(ctx context.Context,
+	login func(ctx context.Context, mount, username string, password cfg.SecretData) (*SecretAuth, error),
+	mount, username string, password PasswordFunc) (cfg.SecretData, time.Time, error) {
+	pass, err := password(ctx)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to get password")
+	}
+
+	auth, err := login(ctx, mount, username, pass)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, expiresAt := authToken(auth)
+	return token, expiresAt, nil
+}
+
+// CreateUserpassUserOptions are options to provide to CreateUserpassUser,
+// docs: https://developer.hashicorp.com/vault/api-docs/auth/userpass#create-update-user
+type CreateUserpassUserOptions struct {
+	// Username is the name of the user to create
+	Username string `json:"-"`
+
+	// Mount is the mount of the userpass auth method, defaults to userpass
+	Mount string `json:"-"`
+
+	Password      string   `json:"password"`
+	TokenTTL      string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL   string   `json:"token_max_ttl,omitempty"`
+	TokenPolicies []string `json:"token_policies,omitempty"`
+}
+
+// CreateUserpassUser creates, or updates, a userpass user in Vault
+func (c *Client) CreateUserpassUser(ctx context.Context, opts *CreateUserpassUserOptions) error {
+	return c.doRequest(ctx, http.MethodPost, userpassUserPath(opts.Mount, opts.Username), opts, nil)
+}
+
+// UpdateUserpassPassword updates the password of a userpass user, an empty
+// mount uses the default mount
+func (c *Client) UpdateUserpassPassword(ctx context.Context, mount, username string, password cfg.SecretData) error {
+	return c.doRequest(ctx, http.MethodPost, path.Join(userpassUserPath(mount, username), "password"), map[string]string{
+		"password": string(password),
+	}, nil)
+}
+
+// ListUserpassUsers returns the names of the userpass users, an empty mount
+// uses the default mount. If there are no users nil is returned.
+func (c *Client) ListUserpassUsers(ctx context.Context, mount string) ([]string, error) {
+	var resp struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	if err := c.doRequest(ctx, "LIST", path.Join("auth", authMount(mount, defaultUserpassMount), "users"), nil, &resp); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return resp.Data.Keys, nil
+}
+
+// DeleteUserpassUser deletes a userpass user, an empty mount uses the
+// default mount
+func (c *Client) DeleteUserpassUser(ctx context.Context, mount, username string) error {
+	return c.doRequest(ctx, http.MethodDelete, userpassUserPath(mount, username), nil, nil)
+}
+
+// userpassUserPath returns the path of a userpass user
+func userpassUserPath(mount, username string) string {
+	return path.Join("auth", authMount(mount, defaultUserpassMount), "users", username)
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeUserpassVault is a fake Vault server implementing the userpass (or
+// ldap) login and user management endpoints on the provided mount.
+type fakeUserpassVault struct {
+	*httptest.Server
+
+	mount string
+
+	mu    sync.Mutex
+	users map[string]string
+}
+
+// newFakeUserpassVault starts a new fakeUserpassVault
+func newFakeUserpassVault(t *testing.T, mount string) *fakeUserpassVault {
+	t.Helper()
+
+	f := &fakeUserpassVault{mount: mount, users: make(map[string]string)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// handle implements http.HandlerFunc
+func (f *fakeUserpassVault) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck // Why: test server
+
+	prefix := "/v1/auth/" + f.mount + "/"
+	endpoint := strings.TrimPrefix(r.URL.Path, prefix)
+	switch {
+	case strings.HasPrefix(endpoint, "login/"):
+		user := strings.TrimPrefix(endpoint, "login/")
+		if pass, ok := f.users[user]; !ok || pass != body["password"] {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["invalid username or password"]}`)) //nolint:errcheck // Why: test server
+			return
+		}
+		fmt.Fprintf(w, `{"auth":{"client_token":"token-%s","lease_duration":3600}}`, user)
+	case r.Method == "LIST" && endpoint == "users":
+		// Vault returns a 404 when there's nothing to list
+		if len(f.users) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`)) //nolint:errcheck // Why: test server
+			return
+		}
+
+		keys := []string{}
+		for user := range f.users {
+			keys = append(keys, user)
+		}
+		sort.Strings(keys)
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"keys": keys}}) //nolint:errcheck // Why: test server
+	case r.Method == http.MethodDelete:
+		delete(f.users, strings.TrimPrefix(endpoint, "users/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.users[strings.TrimSuffix(strings.TrimPrefix(endpoint, "users/"), "/password")] = body["password"]
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestUserpassAuthMethod(t *testing.T) {
+	f := newFakeUserpassVault(t, "userpass")
+	ctx := context.Background()
+	admin := New(WithAddress(f.URL))
+
+	if users, err := admin.ListUserpassUsers(ctx, ""); err != nil || users != nil {
+		t.Errorf("ListUserpassUsers(): expected no users, got %v, %v", users, err)
+	}
+
+	for _, user := range []string{"bob", "alice"} {
+		if err := admin.CreateUserpassUser(ctx, &CreateUserpassUserOptions{Username: user, Password: "hunter2"}); err != nil {
+			t.Errorf("CreateUserpassUser() = %v", err)
+			return
+		}
+	}
+
+	users, err := admin.ListUserpassUsers(ctx, "")
+	if err != nil {
+		t.Errorf("ListUserpassUsers() = %v", err)
+		return
+	}
+
+	if diff := cmp.Diff([]string{"alice", "bob"}, users); diff != "" {
+		t.Errorf("ListUserpassUsers(): %s", diff)
+	}
+
+	c := New(WithAddress(f.URL), WithUserpassAuth(&UserpassAuthOptions{Username: "alice", Password: "hunter2"}))
+	token, _, err := c.opts.am.GetToken(ctx)
+	if err != nil {
+		t.Errorf("GetToken() = %v", err)
+		return
+	}
+
+	if token != "token-alice" {
+		t.Errorf("GetToken(): expected token-alice, got %q", token)
+	}
+
+	if err := admin.UpdateUserpassPassword(ctx, "", "alice", "correct-horse"); err != nil {
+		t.Errorf("UpdateUserpassPassword() = %v", err)
+		return
+	}
+
+	if _, _, err := c.opts.am.GetToken(ctx); err == nil {
+		t.Error("GetToken(): expected the old password to be rejected")
+	}
+
+	prompted := 0
+	c = New(WithAddress(f.URL), WithUserpassAuth(&UserpassAuthOptions{
+		Username: "alice",
+		PasswordFunc: func(context.Context) (cfg.SecretData, error) {
+			prompted++
+			return "correct-horse", nil
+		},
+	}))
+	if _, _, err := c.opts.am.GetToken(ctx); err != nil || prompted != 1 {
+		t.Errorf("GetToken(): expected the password func to be used, got %v", err)
+	}
+
+	if err := admin.DeleteUserpassUser(ctx, "", "bob"); err != nil {
+		t.Errorf("DeleteUserpassUser() = %v", err)
+		return
+	}
+
+	users, err = admin.ListUserpassUsers(ctx, "")
+	if err != nil {
+		t.Errorf("ListUserpassUsers() = %v", err)
+		return
+	}
+
+	if diff := cmp.Diff([]string{"alice"}, users); diff != "" {
+		t.Errorf("ListUserpassUsers(): %s", diff)
+	}
+
+	if _, _, err := New(WithAddress(f.URL), WithUserpassAuth(&UserpassAuthOptions{Username: "alice"})).opts.am.GetToken(ctx); err == nil {
+		t.Error("GetToken(): expected an error without a password")
+	}
+}
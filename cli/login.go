@@ -8,65 +8,143 @@ import (
 	"context"
 	"encoding/json"
 	"os/exec"
-	"strings"
 	"time"
 
+	vault "github.com/getoutreach/vault-client"
 	"github.com/pkg/errors"
 	"k8s.io/client-go/util/jsonpath"
 )
 
 // const defines constants for the Vault CLI
 const (
-	// ProductionAddress is the vault address for the producton Vault server
+	// ProductionAddress is the vault address for the producton Vault server, see ProductionProfile
 	ProductionAddress = "https://vault.outreach.cloud"
 
-	// DevelopmentAddress is the Vault address for the development Vault server
+	// DevelopmentAddress is the Vault address for the development Vault server, see DevelopmentProfile
 	DevelopmentAddress = "https://vault-dev.outreach.cloud/"
 
 	// OidcAuthMethod for using the oidc authentication method to obtain a Vault token
 	OidcAuthMethod = "oidc"
 )
 
-// EnsureLoggedIn ensures that we are authenticated with Vault and have a valid token, returning the token and expiration date.
-func EnsureLoggedIn(ctx context.Context, vaultAddress, authMethod string, minTimeRemaining time.Duration) ([]byte, time.Time, error) {
+// LoginState is the state of the user's Vault login, see IsLoggedIn
+type LoginState string
+
+// Contains the LoginStates returned by IsLoggedIn
+const (
+	// LoginStateLoggedIn means the user has a valid token
+	LoginStateLoggedIn LoginState = "logged_in"
+
+	// LoginStateNotLoggedIn means the user has no token
+	LoginStateNotLoggedIn LoginState = "not_logged_in"
+
+	// LoginStateExpired means the user's token expired
+	LoginStateExpired LoginState = "expired"
+
+	// LoginStateWrongCluster means the user's token was issued by another
+	// Vault server
+	LoginStateWrongCluster LoginState = "wrong_cluster"
+
+	// LoginStateInvalid means Vault rejected the user's token for another
+	// reason, e.g. because it was revoked
+	LoginStateInvalid LoginState = "invalid"
+)
+
+// LoginStatus is the status of the user's Vault login returned by IsLoggedIn
+type LoginStatus struct {
+	// State is the state of the login
+	State LoginState
+
+	// Token is the user's token, it's set unless State is
+	// LoginStateNotLoggedIn
+	Token []byte
+
+	// ExpiresAt is when the token expires, it's zero if the token doesn't
+	// expire or State isn't LoginStateLoggedIn
+	ExpiresAt time.Time
+
+	// Source is where the token was read from, VAULT_TOKEN or the path of
+	// the token file
+	Source string
+}
+
+// EnsureLoggedIn ensures that we are authenticated with the Vault server of the named profile, see LoadProfile, and have a
+// valid token, returning the token and expiration date. An empty name uses the default profile.
+func EnsureLoggedIn(ctx context.Context, profile string, minTimeRemaining time.Duration) ([]byte, time.Time, error) {
+	p, err := LoadProfile(profile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return EnsureLoggedInWithProfile(ctx, p, minTimeRemaining)
+}
+
+// EnsureLoggedInWithProfile ensures that we are authenticated with the Vault server of p and have a valid token, returning
+// the token and expiration date. The oidc auth method is handled natively, see OIDCLogin, other auth methods are delegated
+// to `vault login`. Tokens of named profiles are cached per profile, otherwise they're persisted with the vault CLI's
+// token helper.
+func EnsureLoggedInWithProfile(ctx context.Context, p *Profile, minTimeRemaining time.Duration) ([]byte, time.Time, error) {
 	// Check if we need to issue a new token
-	var refresh bool
-	token, expiresAt, err := IsLoggedIn(ctx, vaultAddress)
+	status, err := IsLoggedInWithProfile(ctx, p)
 	if err != nil {
 		return nil, time.Time{}, err
 	}
 
-	if token == nil {
-		// No token found
-		refresh = true
-	} else if time.Until(expiresAt) < minTimeRemaining {
-		// Insufficient time remaining, refresh anyway
-		refresh = true
-	}
-
-	if refresh {
-		// Issue a new token using our authentication method
-		//nolint:lll // Why: Passing in the vault address and method
-		args := []string{"login", "-format", "json", "-method", authMethod, "-address", vaultAddress}
-		_, err := exec.CommandContext(ctx, "vault", args...).Output()
+	if status.State == LoginStateLoggedIn && (status.ExpiresAt.IsZero() || time.Until(status.ExpiresAt) >= minTimeRemaining) {
+		return status.Token, status.ExpiresAt, nil
+	}
+
+	if p.authMethod() == OidcAuthMethod {
+		// Run the OIDC flow ourselves, so the vault CLI isn't needed
+		token, expiresAt, err := OIDCLogin(ctx, &OIDCLoginOptions{
+			Address:   p.Address,
+			Namespace: p.Namespace,
+			TLSConfig: p.tlsConfig(),
+			Mount:     p.Mount,
+			Role:      p.Role,
+		})
 		if err != nil {
-			var execErr *exec.ExitError
-			if errors.As(err, &execErr) {
-				return nil, time.Time{}, errors.Wrapf(err, "failed to run vault login: %s", execErr.Stderr)
-			}
+			return nil, time.Time{}, err
+		}
 
-			return nil, time.Time{}, errors.Wrap(err, "failed to run vault login (no stderr)")
+		if err := saveToken(ctx, p, token, expiresAt); err != nil {
+			return nil, time.Time{}, err
 		}
+		return token, expiresAt, nil
+	}
 
-		// The login above only returns a little info about the token, so re-request info about the token to get full
-		// info about expiry/validity.
-		token, expiresAt, err = IsLoggedIn(ctx, vaultAddress)
-		if err != nil {
-			return nil, time.Time{}, errors.Wrap(err, "failed to parse token output")
+	// Issue a new token using our authentication method, the vault CLI
+	// persists it with its token helper unless the profile is named
+	output, err := exec.CommandContext(ctx, "vault", p.vaultCLIArgs()...).Output()
+	if err != nil {
+		var execErr *exec.ExitError
+		if errors.As(err, &execErr) {
+			return nil, time.Time{}, errors.Wrapf(err, "failed to run vault login: %s", execErr.Stderr)
 		}
+
+		return nil, time.Time{}, errors.Wrap(err, "failed to run vault login (no stderr)")
+	}
+
+	token, err := cmdOutputToToken(output, "{$.auth.client_token}")
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "failed to parse vault login output")
 	}
 
-	return token, expiresAt, nil
+	// The login above only returns a little info about the token, so re-request info about the token to get full
+	// info about expiry/validity.
+	info, err := lookupToken(ctx, p, token)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "failed to lookup vault token")
+	}
+
+	if p.Name != "" {
+		err = saveToken(ctx, p, token, info.ExpireTime)
+	} else {
+		err = saveTokenInfo(p, token, info.ExpireTime)
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return token, info.ExpireTime, nil
 }
 
 // cmdOutputToToken converts vault token lookup and vault token login output to
@@ -86,42 +164,83 @@ func cmdOutputToToken(in []byte, expr string) ([]byte, error) {
 	return buf.Bytes(), errors.Wrapf(err, "failed to execute jsonpath %q", expr)
 }
 
-// IsLoggedIn returns a valid token and expiration time if it is not expired
-func IsLoggedIn(ctx context.Context, vaultAddress string) ([]byte, time.Time, error) {
-	args := []string{"token", "lookup", "-format", "json", "-address", vaultAddress}
-	output, err := exec.CommandContext(ctx, "vault", args...).CombinedOutput()
+// IsLoggedIn returns the status of the user's Vault login for the named
+// profile, see LoadProfile. An empty name uses the default profile.
+func IsLoggedIn(ctx context.Context, profile string) (*LoginStatus, error) {
+	p, err := LoadProfile(profile)
 	if err != nil {
-		if strings.Contains(string(output), "permission denied") {
-			return nil, time.Time{}, nil
-		}
-		return nil, time.Time{}, errors.Wrapf(err, "failed to lookup vault token: %s", output)
+		return nil, err
 	}
+	return IsLoggedInWithProfile(ctx, p)
+}
 
-	token, expireTime, err := parseTokenOutput(output)
+// IsLoggedInWithProfile returns the status of the user's Vault login for p.
+// Named profiles read the token from their token cache, otherwise the token
+// is read the same way the vault CLI does, from VAULT_TOKEN or the token
+// helper. The token is looked up at the profile's Vault server. An error is
+// only returned if the status couldn't be determined, e.g. because Vault is
+// unreachable.
+func IsLoggedInWithProfile(ctx context.Context, p *Profile) (*LoginStatus, error) {
+	src, err := findTokenSource(p)
 	if err != nil {
-		return nil, time.Time{}, errors.Wrapf(err, "failed to parse token output: %s", output)
+		return nil, err
 	}
 
-	log.InfoContext(ctx, "Logged into Vault", "expires", expireTime, "address", vaultAddress)
-	return token, expireTime, nil
-}
-
-// parseTokenOutput parses the raw output from the vault CLI to get attributes of a token
-func parseTokenOutput(output []byte) ([]byte, time.Time, error) {
-	expire, err := cmdOutputToToken(output, "{$.data.expire_time}")
+	token, err := src.readToken(ctx)
 	if err != nil {
-		return nil, time.Time{}, errors.Wrap(err, "failed to vault output expire_time jsonpath")
+		return nil, errors.Wrapf(err, "failed to read vault token from %s", src.name)
 	}
 
-	expireTime, err := time.Parse(time.RFC3339Nano, string(expire))
-	if err != nil {
-		return nil, time.Time{}, errors.Wrap(err, "failed to parse expire_time")
+	if token == nil {
+		return &LoginStatus{State: LoginStateNotLoggedIn}, nil
 	}
 
-	token, err := cmdOutputToToken(output, "{$.data.id}")
+	status := &LoginStatus{Token: token, Source: src.name}
+	info, err := lookupToken(ctx, p, token)
+	if vault.IsPermissionDenied(err) {
+		status.State = rejectedTokenState(p, token)
+		return status, nil
+	}
 	if err != nil {
-		return nil, time.Time{}, errors.Wrap(err, "failed to vault output token jsonpath")
+		return nil, errors.Wrap(err, "failed to lookup vault token")
 	}
 
-	return token, expireTime, nil
+	status.State, status.ExpiresAt = LoginStateLoggedIn, info.ExpireTime
+	if src.name != tokenEnv {
+		recordTokenInfo(ctx, p, token, info.ExpireTime)
+	}
+	log.InfoContext(ctx, "Logged into Vault", "expires", status.ExpiresAt, "address", p.Address, "profile", p.Name)
+	return status, nil
+}
+
+// recordTokenInfo records where the token of p was issued and when it
+// expires, as looked up at the profile's Vault server, unless that's
+// already recorded. This covers tokens obtained outside of this package,
+// e.g. by `vault login`, so rejectedTokenState can tell why they're
+// rejected later on. Failing to record it isn't fatal.
+func recordTokenInfo(ctx context.Context, p *Profile, token []byte, expiresAt time.Time) {
+	info := loadTokenInfo(p, token)
+	if info != nil && info.Address == normalizeAddress(p.Address) && info.ExpiresAt.Equal(expiresAt) {
+		return
+	}
+
+	if err := saveTokenInfo(p, token, expiresAt); err != nil {
+		log.WarnContext(ctx, "Failed to record vault token info", "error", err)
+	}
+}
+
+// rejectedTokenState returns why Vault rejected the token of p, based on
+// what was recorded when it was obtained or last looked up.
+func rejectedTokenState(p *Profile, token []byte) LoginState {
+	info := loadTokenInfo(p, token)
+	switch {
+	case info == nil:
+		return LoginStateInvalid
+	case info.Address != normalizeAddress(p.Address):
+		return LoginStateWrongCluster
+	case !info.ExpiresAt.IsZero() && time.Now().After(info.ExpiresAt):
+		return LoginStateExpired
+	default:
+		return LoginStateInvalid
+	}
 }
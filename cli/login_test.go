@@ -4,8 +4,16 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 )
@@ -97,3 +105,200 @@ func TestVaultLoginTokenJSONPath(t *testing.T) {
 		assert.Equal(t, test.expected, string(actual), name)
 	}
 }
+
+// newFakeLookupVault starts a fake Vault server that only accepts the token
+// "valid", which expires in an hour
+func newFakeLookupVault(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/token/lookup-self" || r.Header.Get("Authorization") != "Bearer valid" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`)) //nolint:errcheck // Why: test server
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"id":"valid","renewable":true,"expire_time":%q}}`, expiresAt)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestIsLoggedIn(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeLookupVault(t)
+	p := &Profile{Address: srv.URL}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("VAULT_CONFIG_PATH", "")
+	t.Setenv(tokenEnv, "")
+
+	status, err := IsLoggedInWithProfile(ctx, p)
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateNotLoggedIn, status.State)
+
+	assert.NilError(t, os.WriteFile(filepath.Join(home, ".vault-token"), []byte("valid\n"), 0o600))
+	status, err = IsLoggedInWithProfile(ctx, p)
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateLoggedIn, status.State)
+	assert.Equal(t, "valid", string(status.Token))
+	assert.Equal(t, filepath.Join(home, ".vault-token"), status.Source)
+	assert.Assert(t, time.Until(status.ExpiresAt) > 59*time.Minute)
+
+	// VAULT_TOKEN takes precedence over the token file
+	t.Setenv(tokenEnv, "unknown")
+	status, err = IsLoggedInWithProfile(ctx, p)
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateInvalid, status.State)
+	assert.Equal(t, tokenEnv, status.Source)
+
+	assert.NilError(t, saveTokenInfo(p, []byte("unknown"), time.Now().Add(-time.Minute)))
+	status, err = IsLoggedInWithProfile(ctx, p)
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateExpired, status.State)
+
+	assert.NilError(t, saveTokenInfo(&Profile{Address: ProductionAddress}, []byte("unknown"), time.Now().Add(time.Hour)))
+	status, err = IsLoggedInWithProfile(ctx, p)
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateWrongCluster, status.State)
+}
+
+func TestEnsureLoggedInWithValidToken(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeLookupVault(t)
+	t.Setenv(tokenEnv, "valid")
+
+	token, expiresAt, err := EnsureLoggedInWithProfile(ctx, &Profile{Address: srv.URL}, time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, "valid", string(token))
+	assert.Assert(t, time.Until(expiresAt) > 59*time.Minute)
+}
+
+func TestIsLoggedInWithTokenHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake token helper is a shell script")
+	}
+
+	ctx := context.Background()
+	srv := newFakeLookupVault(t)
+	p := &Profile{Address: srv.URL}
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(tokenEnv, "")
+
+	helper := filepath.Join(dir, "helper")
+	script := "#!/bin/sh\ncase \"$1\" in\nget) cat " + dir + "/token 2>/dev/null || true ;;\nstore) cat > " + dir + "/token ;;\nesac\n"
+	assert.NilError(t, os.WriteFile(helper, []byte(script), 0o700))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "config.hcl"), []byte(`token_helper = "`+helper+`"`), 0o600))
+	t.Setenv("VAULT_CONFIG_PATH", filepath.Join(dir, "config.hcl"))
+
+	status, err := IsLoggedInWithProfile(ctx, p)
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateNotLoggedIn, status.State)
+
+	// tokens are persisted with the token helper, rather than ~/.vault-token
+	assert.NilError(t, saveToken(ctx, p, []byte("valid"), time.Now().Add(time.Hour)))
+	_, err = os.Stat(filepath.Join(dir, ".vault-token"))
+	assert.Assert(t, os.IsNotExist(err), "expected no token file, got %v", err)
+
+	status, err = IsLoggedInWithProfile(ctx, p)
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateLoggedIn, status.State)
+	assert.Equal(t, helper, status.Source)
+}
+
+func TestEnsureLoggedInPerProfile(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeLookupVault(t)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(ProfilesPathEnv, "")
+	t.Setenv("VAULT_CONFIG_PATH", "")
+	writeProfiles(t, home, "profiles:\n  dev:\n    address: "+srv.URL+"\n  prod:\n    address: "+srv.URL+"\n")
+
+	// named profiles don't read VAULT_TOKEN
+	t.Setenv(tokenEnv, "valid")
+	status, err := IsLoggedIn(ctx, "dev")
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateNotLoggedIn, status.State)
+
+	dev, err := LoadProfile("dev")
+	assert.NilError(t, err)
+	assert.NilError(t, saveToken(ctx, dev, []byte("valid"), time.Now().Add(time.Hour)))
+
+	token, _, err := EnsureLoggedIn(ctx, "dev", time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, "valid", string(token))
+
+	// logging into dev doesn't change the token of prod, or the vault CLI
+	status, err = IsLoggedIn(ctx, "prod")
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateNotLoggedIn, status.State)
+
+	_, err = os.Stat(filepath.Join(home, ".vault-token"))
+	assert.Assert(t, os.IsNotExist(err), "expected no token file, got %v", err)
+
+	prod, err := LoadProfile("prod")
+	assert.NilError(t, err)
+	assert.NilError(t, saveToken(ctx, prod, []byte("revoked"), time.Now().Add(time.Hour)))
+
+	status, err = IsLoggedIn(ctx, "prod")
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateInvalid, status.State)
+	assert.Equal(t, filepath.Join(home, profileTokensDir, "prod"), status.Source)
+
+	status, err = IsLoggedIn(ctx, "dev")
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateLoggedIn, status.State)
+
+	_, err = IsLoggedIn(ctx, "unknown")
+	assert.ErrorContains(t, err, "unknown profile")
+}
+
+func TestIsLoggedInWithExternalToken(t *testing.T) {
+	ctx := context.Background()
+
+	// a token obtained outside of this package, e.g. by `vault login`, so
+	// there is no token info recorded for it
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("VAULT_CONFIG_PATH", "")
+	t.Setenv(tokenEnv, "")
+	assert.NilError(t, os.WriteFile(filepath.Join(home, ".vault-token"), []byte("external"), 0o600))
+
+	expiresAt := time.Now().Add(200 * time.Millisecond)
+	var revoked atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if revoked.Load() || r.Header.Get("Authorization") != "Bearer external" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`)) //nolint:errcheck // Why: test server
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"id":"external","expire_time":%q}}`, expiresAt.UTC().Format(time.RFC3339Nano))
+	}))
+	defer srv.Close()
+
+	p := &Profile{Address: srv.URL}
+	status, err := IsLoggedInWithProfile(ctx, p)
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateLoggedIn, status.State)
+
+	// the lookup is recorded, so rejections by other clusters are recognized
+	info := loadTokenInfo(p, []byte("external"))
+	assert.Assert(t, info != nil, "expected the token info to be recorded")
+	assert.Equal(t, normalizeAddress(srv.URL), info.Address)
+
+	status, err = IsLoggedInWithProfile(ctx, &Profile{Address: newFakeLookupVault(t).URL})
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateWrongCluster, status.State)
+
+	// and so is the token expiring
+	revoked.Store(true)
+	time.Sleep(time.Until(expiresAt) + 10*time.Millisecond)
+	status, err = IsLoggedInWithProfile(ctx, p)
+	assert.NilError(t, err)
+	assert.Equal(t, LoginStateExpired, status.State)
+}
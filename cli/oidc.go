@@ -0,0 +1,226 @@
+// Copyright 2023 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements the OIDC browser login flow
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"time"
+
+	vault "github.com/getoutreach/vault-client"
+	"github.com/pkg/errors"
+)
+
+// DefaultOIDCListenAddress is the address the OIDC callback listener
+// listens on by default, it matches the vault CLI's default so the same
+// allowed_redirect_uris work for both.
+const DefaultOIDCListenAddress = "localhost:8250"
+
+// oidcCallbackPath is the path of the OIDC callback listener
+const oidcCallbackPath = "/oidc/callback"
+
+// OIDCLoginOptions are options for OIDCLogin
+type OIDCLoginOptions struct {
+	// Address is the address of the Vault server
+	Address string
+
+	// Namespace is the Vault namespace to log into, if any
+	Namespace string
+
+	// TLSConfig configures how Vault's certificate is verified, if nil
+	// the system's CA certificates are used
+	TLSConfig *vault.TLSConfig
+
+	// Mount is the mount of the oidc auth method, defaults to oidc
+	Mount string
+
+	// Role is the role to log in as, if empty the mount's default_role is
+	// used
+	Role string
+
+	// ListenAddress is the address the callback listener listens on,
+	// defaults to DefaultOIDCListenAddress. The redirect URI,
+	// http://<ListenAddress>/oidc/callback, must be an allowed redirect
+	// URI of the role.
+	ListenAddress string
+
+	// OpenURL opens the OIDC provider's URL for the user, defaults to
+	// opening it in the user's browser.
+	OpenURL func(url string) error
+}
+
+// oidcResult is the result of the OIDC callback
+type oidcResult struct {
+	auth *vault.SecretAuth
+	err  error
+}
+
+// OIDCLogin logs into Vault using the OIDC auth method: it opens the OIDC
+// provider's login page in the browser and waits, until ctx is done, for
+// the provider to redirect back to a localhost callback listener. It
+// returns the token and when it expires, which is zero if it doesn't.
+func OIDCLogin(ctx context.Context, opts *OIDCLoginOptions) ([]byte, time.Time, error) {
+	listenAddress := opts.ListenAddress
+	if listenAddress == "" {
+		listenAddress = DefaultOIDCListenAddress
+	}
+
+	openURL := opts.OpenURL
+	if openURL == nil {
+		openURL = openBrowser
+	}
+
+	ln, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "failed to start oidc callback listener")
+	}
+	defer ln.Close()
+
+	redirectURI, err := oidcRedirectURI(listenAddress, ln.Addr())
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	nonce, err := oidcNonce()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	c := vault.New(vault.WithAddress(opts.Address), vault.WithNamespace(opts.Namespace), vault.WithTLSConfig(opts.TLSConfig))
+	authURL, err := c.OIDCAuthURL(ctx, opts.Mount, opts.Role, redirectURI, nonce)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "failed to get oidc auth url")
+	}
+
+	results := make(chan oidcResult, 1)
+	srv := &http.Server{
+		Handler:           oidcCallbackHandler(ctx, c, opts.Mount, nonce, results),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go srv.Serve(ln) //nolint:errcheck // Why: always returns an error once closed
+	defer srv.Close()
+
+	log.InfoContext(ctx, "Complete the login with your OIDC provider in the browser", "url", authURL)
+	if err := openURL(authURL); err != nil {
+		log.WarnContext(ctx, "Failed to open browser, open the url manually", "url", authURL, "error", err)
+	}
+
+	var res oidcResult
+	select {
+	case res = <-results:
+	case <-ctx.Done():
+		return nil, time.Time{}, errors.Wrap(ctx.Err(), "timed out waiting for oidc login")
+	}
+
+	if res.err != nil {
+		return nil, time.Time{}, res.err
+	}
+
+	var expiresAt time.Time
+	if res.auth.LeaseDuration > 0 {
+		expiresAt = time.Now().Add(time.Duration(res.auth.LeaseDuration) * time.Second)
+	}
+
+	log.InfoContext(ctx, "Logged into Vault", "expires", expiresAt, "address", opts.Address)
+	return []byte(res.auth.ClientToken), expiresAt, nil
+}
+
+// oidcCallbackHandler returns the handler of the callback listener, it
+// finishes the login with Vault and sends the result on results.
+func oidcCallbackHandler(ctx context.Context, c *vault.Client, mount, nonce string,
+	results chan<- oidcResult) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidcCallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var res oidcResult
+		if q.Get("error") != "" {
+			res.err = errors.Errorf("oidc provider returned an error: %s %s", q.Get("error"), q.Get("error_description"))
+		} else {
+			res.auth, res.err = c.OIDCCallback(ctx, &vault.OIDCCallbackOptions{
+				Mount:       mount,
+				State:       q.Get("state"),
+				Code:        q.Get("code"),
+				IDToken:     q.Get("id_token"),
+				ClientNonce: nonce,
+			})
+			res.err = errors.Wrap(res.err, "failed to finish oidc login")
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if res.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, oidcResponseHTML, "Vault login failed", html.EscapeString(res.err.Error()))
+		} else {
+			fmt.Fprintf(w, oidcResponseHTML, "Vault login successful", "You can close this window.")
+		}
+
+		// only the first callback is used
+		select {
+		case results <- res:
+		default:
+		}
+	})
+	return mux
+}
+
+// oidcResponseHTML is the page shown in the browser after the callback
+const oidcResponseHTML = `<!DOCTYPE html>
+<html><head><title>%[1]s</title></head>
+<body><h1>%[1]s</h1><p>%[2]s</p></body></html>
+`
+
+// oidcRedirectURI returns the redirect URI for a callback listener that was
+// asked to listen on listenAddress and listens on addr, which differ if the
+// port was picked by the OS.
+func oidcRedirectURI(listenAddress string, addr net.Addr) (string, error) {
+	host, _, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid listen address %q", listenAddress)
+	}
+
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return "", errors.Errorf("unexpected listener address %s", addr)
+	}
+
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(host, fmt.Sprint(tcpAddr.Port)), oidcCallbackPath), nil
+}
+
+// oidcNonce returns a random client nonce, which ties the callback to the
+// login that was started by this process.
+func oidcNonce() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate oidc nonce")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// openBrowser opens url in the user's browser
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// reap the process once the browser was launched
+	go cmd.Wait() //nolint:errcheck // Why: best effort
+	return nil
+}
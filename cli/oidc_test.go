@@ -0,0 +1,139 @@
+// Copyright 2023 Outreach Corporation. All Rights Reserved.
+
+// Description: tests the OIDC browser login flow
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakeOIDC is a fake Vault server with an oidc auth method, backed by a fake
+// OIDC provider that immediately redirects back with a code.
+type fakeOIDC struct {
+	vault *httptest.Server
+	idp   *httptest.Server
+
+	// idpError, if set, is returned by the provider instead of a code
+	idpError string
+
+	redirectURI string
+	nonce       string
+}
+
+// newFakeOIDC starts a new fakeOIDC
+func newFakeOIDC(t *testing.T) *fakeOIDC {
+	t.Helper()
+
+	f := &fakeOIDC{}
+	f.vault = httptest.NewServer(http.HandlerFunc(f.handleVault))
+	f.idp = httptest.NewServer(http.HandlerFunc(f.handleIDP))
+	t.Cleanup(f.vault.Close)
+	t.Cleanup(f.idp.Close)
+	return f
+}
+
+// handleVault implements the oidc auth_url and callback endpoints
+func (f *fakeOIDC) handleVault(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/auth/oidc/oidc/auth_url":
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		f.redirectURI, f.nonce = req["redirect_uri"], req["client_nonce"]
+		authURL := f.idp.URL + "/authorize?" + url.Values{"redirect_uri": {f.redirectURI}, "state": {"state"}}.Encode()
+		fmt.Fprintf(w, `{"data":{"auth_url":%q}}`, authURL)
+	case "/v1/auth/oidc/oidc/callback":
+		q := r.URL.Query()
+		if q.Get("state") != "state" || q.Get("code") != "code" || q.Get("client_nonce") != f.nonce {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["invalid callback"]}`)) //nolint:errcheck // Why: test server
+			return
+		}
+		w.Write([]byte(`{"auth":{"client_token":"oidc-token","lease_duration":3600}}`)) //nolint:errcheck // Why: test server
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// handleIDP redirects back to the redirect URI, like a provider would once
+// the user logged in
+func (f *fakeOIDC) handleIDP(w http.ResponseWriter, r *http.Request) {
+	q := url.Values{"state": {r.URL.Query().Get("state")}, "code": {"code"}}
+	if f.idpError != "" {
+		q = url.Values{"error": {f.idpError}}
+	}
+	http.Redirect(w, r, r.URL.Query().Get("redirect_uri")+"?"+q.Encode(), http.StatusFound)
+}
+
+// openURL opens url like a browser would
+func openURL(url string) error {
+	go func() {
+		resp, err := http.Get(url) //nolint:gosec // Why: test
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	return nil
+}
+
+func TestOIDCLogin(t *testing.T) {
+	f := newFakeOIDC(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	token, expiresAt, err := OIDCLogin(ctx, &OIDCLoginOptions{
+		Address:       f.vault.URL,
+		ListenAddress: "localhost:0",
+		OpenURL:       openURL,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, "oidc-token", string(token))
+	assert.Assert(t, time.Until(expiresAt) > 59*time.Minute, "expected the token to expire in an hour, got %s", expiresAt)
+
+	u, err := url.Parse(f.redirectURI)
+	assert.NilError(t, err)
+	assert.Equal(t, "localhost", u.Hostname())
+	assert.Equal(t, oidcCallbackPath, u.Path)
+}
+
+func TestOIDCLoginProviderError(t *testing.T) {
+	f := newFakeOIDC(t)
+	f.idpError = "access_denied"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _, err := OIDCLogin(ctx, &OIDCLoginOptions{
+		Address:       f.vault.URL,
+		ListenAddress: "localhost:0",
+		OpenURL:       openURL,
+	})
+	assert.ErrorContains(t, err, "access_denied")
+}
+
+func TestOIDCLoginTimeout(t *testing.T) {
+	f := newFakeOIDC(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, _, err := OIDCLogin(ctx, &OIDCLoginOptions{
+		Address:       f.vault.URL,
+		ListenAddress: "localhost:0",
+		OpenURL:       func(string) error { return nil },
+	})
+	assert.ErrorContains(t, err, "timed out")
+}
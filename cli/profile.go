@@ -0,0 +1,278 @@
+// Copyright 2023 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements named Vault environment profiles
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	vault "github.com/getoutreach/vault-client"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Contains the built-in profiles, they're available even if there is no
+// profiles file
+const (
+	// ProductionProfile is the profile of the production Vault server
+	ProductionProfile = "production"
+
+	// DevelopmentProfile is the profile of the development Vault server
+	DevelopmentProfile = "development"
+)
+
+// ProfilesPathEnv is the environment variable that overrides the path of
+// the profiles file
+const ProfilesPathEnv = "VAULT_CLIENT_PROFILES"
+
+// profilesPath is the path, relative to the home directory, of the
+// profiles file
+var profilesPath = filepath.Join(".config", "vault-client", "profiles.yaml")
+
+// profileNameRegexp matches valid profile names, they're used as file names
+// of the profile's token cache. Names ending in ".json" are rejected too.
+var profileNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]*$`)
+
+// Profile describes a Vault environment and how to log into it
+type Profile struct {
+	// Name is the name of the profile. Tokens of a named profile are
+	// cached per profile, an unnamed profile uses VAULT_TOKEN and the
+	// vault CLI's token helper instead.
+	Name string `yaml:"-"`
+
+	// Address is the address of the Vault server. Required.
+	Address string `yaml:"address"`
+
+	// Namespace is the Vault namespace to log into, if any
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// AuthMethod is the auth method to log in with, defaults to oidc
+	AuthMethod string `yaml:"auth_method,omitempty"`
+
+	// Mount is the mount of the auth method, defaults to the auth
+	// method's name
+	Mount string `yaml:"mount,omitempty"`
+
+	// Role is the role to log in as, if empty the auth method's default
+	// role is used
+	Role string `yaml:"role,omitempty"`
+
+	// TLS configures how Vault's certificate is verified
+	TLS *ProfileTLS `yaml:"tls,omitempty"`
+}
+
+// ProfileTLS is the TLS configuration of a Profile, see vault.TLSConfig
+type ProfileTLS struct {
+	// CACert is the path to a PEM encoded CA certificate file used to
+	// verify Vault's certificate
+	CACert string `yaml:"ca_cert,omitempty"`
+
+	// CAPath is the path to a directory of PEM encoded CA certificate
+	// files used to verify Vault's certificate
+	CAPath string `yaml:"ca_path,omitempty"`
+
+	// ClientCert is the path to a PEM encoded client certificate
+	// presented to Vault
+	ClientCert string `yaml:"client_cert,omitempty"`
+
+	// ClientKey is the path to the PEM encoded private key of ClientCert
+	ClientKey string `yaml:"client_key,omitempty"`
+
+	// ServerName is the name used for SNI and to verify Vault's
+	// certificate
+	ServerName string `yaml:"server_name,omitempty"`
+
+	// Insecure disables verification of Vault's certificate
+	Insecure bool `yaml:"insecure,omitempty"`
+}
+
+// Profiles is the contents of the profiles file, e.g.
+//
+//	default: dev
+//	profiles:
+//	  dev:
+//	    address: https://vault-dev.example.com
+//	    auth_method: oidc
+//	    role: engineer
+type Profiles struct {
+	// Default is the name of the profile used if none is provided
+	Default string `yaml:"default,omitempty"`
+
+	// Profiles are the profiles by name, they're added to, and replace,
+	// the built-in profiles
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// builtinProfiles returns the profiles available without a profiles file
+func builtinProfiles() map[string]*Profile {
+	return map[string]*Profile{
+		ProductionProfile:  {Address: ProductionAddress, AuthMethod: OidcAuthMethod},
+		DevelopmentProfile: {Address: DevelopmentAddress, AuthMethod: OidcAuthMethod},
+	}
+}
+
+// LoadProfiles reads the profiles file, ~/.config/vault-client/profiles.yaml
+// or the path in VAULT_CLIENT_PROFILES. If the file doesn't exist only the
+// built-in profiles are returned.
+func LoadProfiles() (*Profiles, error) {
+	path := os.Getenv(ProfilesPathEnv)
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find home directory")
+		}
+		path = filepath.Join(home, profilesPath)
+	}
+
+	var profiles Profiles
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read profiles")
+	}
+
+	if err := yaml.Unmarshal(b, &profiles); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse profiles %s", path)
+	}
+
+	all := builtinProfiles()
+	for name, p := range profiles.Profiles {
+		if p == nil {
+			return nil, errors.Errorf("profile %q in %s is empty", name, path)
+		}
+		all[name] = p
+	}
+	profiles.Profiles = all
+
+	for name, p := range profiles.Profiles {
+		p.Name = name
+		if err := p.validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid profile in %s", path)
+		}
+	}
+	return &profiles, nil
+}
+
+// Get returns the profile with the provided name, or the default profile if
+// name is empty
+func (p *Profiles) Get(name string) (*Profile, error) {
+	if name == "" {
+		name = p.Default
+	}
+
+	if name == "" {
+		return nil, errors.New("no profile provided and no default profile configured")
+	}
+
+	profile, ok := p.Profiles[name]
+	if !ok {
+		return nil, errors.Errorf("unknown profile %q", name)
+	}
+	return profile, nil
+}
+
+// LoadProfile returns the profile with the provided name from the profiles
+// file, see LoadProfiles and Profiles.Get
+func LoadProfile(name string) (*Profile, error) {
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	return profiles.Get(name)
+}
+
+// validate returns an error if the profile can't be used
+func (p *Profile) validate() error {
+	if !profileNameRegexp.MatchString(p.Name) {
+		return errors.Errorf("profile name %q may only contain letters, digits, '_', '-' and '.'", p.Name)
+	}
+
+	// the token cache of the profile would be the token info file of the
+	// profile named without the suffix, see tokenInfoFile
+	if strings.HasSuffix(strings.ToLower(p.Name), ".json") {
+		return errors.Errorf("profile name %q may not end in '.json'", p.Name)
+	}
+
+	if p.Address == "" {
+		return errors.Errorf("profile %q has no address", p.Name)
+	}
+	return nil
+}
+
+// authMethod returns the auth method of the profile
+func (p *Profile) authMethod() string {
+	if p.AuthMethod == "" {
+		return OidcAuthMethod
+	}
+	return p.AuthMethod
+}
+
+// tlsConfig returns the TLS configuration of the profile, or nil if there
+// is none
+func (p *Profile) tlsConfig() *vault.TLSConfig {
+	if p.TLS == nil {
+		return nil
+	}
+
+	return &vault.TLSConfig{
+		CACert:     p.TLS.CACert,
+		CAPath:     p.TLS.CAPath,
+		ClientCert: p.TLS.ClientCert,
+		ClientKey:  p.TLS.ClientKey,
+		ServerName: p.TLS.ServerName,
+		Insecure:   p.TLS.Insecure,
+	}
+}
+
+// clientOpts returns the options of a vault client talking to the profile's
+// Vault server
+func (p *Profile) clientOpts() []vault.Opts {
+	opts := []vault.Opts{vault.WithAddress(p.Address), vault.WithNamespace(p.Namespace)}
+	if conf := p.tlsConfig(); conf != nil {
+		opts = append(opts, vault.WithTLSConfig(conf))
+	}
+	return opts
+}
+
+// vaultCLIArgs returns the arguments of `vault login` for the profile
+func (p *Profile) vaultCLIArgs() []string {
+	args := []string{"login", "-format", "json", "-method", p.authMethod(), "-address", p.Address}
+	if p.Namespace != "" {
+		args = append(args, "-namespace", p.Namespace)
+	}
+
+	if p.Mount != "" {
+		args = append(args, "-path", p.Mount)
+	}
+
+	if conf := p.TLS; conf != nil {
+		for _, f := range []struct{ flag, value string }{
+			{"-ca-cert", conf.CACert},
+			{"-ca-path", conf.CAPath},
+			{"-client-cert", conf.ClientCert},
+			{"-client-key", conf.ClientKey},
+			{"-tls-server-name", conf.ServerName},
+		} {
+			if f.value != "" {
+				args = append(args, f.flag, f.value)
+			}
+		}
+
+		if conf.Insecure {
+			args = append(args, "-tls-skip-verify")
+		}
+	}
+
+	// named profiles cache their own token, so the vault CLI mustn't
+	// replace the token in its token helper
+	if p.Name != "" {
+		args = append(args, "-no-store")
+	}
+
+	if p.Role != "" {
+		args = append(args, "role="+p.Role)
+	}
+	return args
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 Outreach Corporation. All Rights Reserved.
+
+// Description: tests loading Vault environment profiles
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// writeProfiles writes a profiles file to the profiles path of home
+func writeProfiles(t *testing.T, home, contents string) {
+	t.Helper()
+
+	path := filepath.Join(home, profilesPath)
+	assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	assert.NilError(t, os.WriteFile(path, []byte(contents), 0o600))
+}
+
+func TestLoadProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(ProfilesPathEnv, "")
+
+	// only the built-in profiles exist without a profiles file
+	profiles, err := LoadProfiles()
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(profiles.Profiles))
+	assert.Equal(t, ProductionAddress, profiles.Profiles[ProductionProfile].Address)
+	assert.Equal(t, DevelopmentProfile, profiles.Profiles[DevelopmentProfile].Name)
+
+	_, err = profiles.Get("")
+	assert.ErrorContains(t, err, "no default profile")
+
+	writeProfiles(t, home, `
+default: staging
+profiles:
+  staging:
+    address: https://vault-staging.example.com
+    namespace: team
+    auth_method: jwt
+    mount: gitlab
+    role: ci
+    tls:
+      ca_cert: /etc/ca.pem
+      insecure: true
+  production:
+    address: https://vault.example.com
+`)
+
+	profiles, err = LoadProfiles()
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(profiles.Profiles))
+
+	p, err := profiles.Get("")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Profile{
+		Name:       "staging",
+		Address:    "https://vault-staging.example.com",
+		Namespace:  "team",
+		AuthMethod: "jwt",
+		Mount:      "gitlab",
+		Role:       "ci",
+		TLS:        &ProfileTLS{CACert: "/etc/ca.pem", Insecure: true},
+	}, p)
+
+	// profiles in the file replace the built-in profiles
+	p, err = LoadProfile(ProductionProfile)
+	assert.NilError(t, err)
+	assert.Equal(t, "https://vault.example.com", p.Address)
+	assert.Equal(t, OidcAuthMethod, p.authMethod())
+
+	_, err = profiles.Get("unknown")
+	assert.ErrorContains(t, err, `unknown profile "unknown"`)
+
+	// VAULT_CLIENT_PROFILES overrides the path of the profiles file
+	other := filepath.Join(t.TempDir(), "profiles.yaml")
+	assert.NilError(t, os.WriteFile(other, []byte("default: development\n"), 0o600))
+	t.Setenv(ProfilesPathEnv, other)
+
+	p, err = LoadProfile("")
+	assert.NilError(t, err)
+	assert.Equal(t, DevelopmentAddress, p.Address)
+}
+
+func TestLoadProfilesInvalid(t *testing.T) {
+	tests := map[string]struct {
+		contents string
+		err      string
+	}{
+		"invalid yaml":    {contents: "profiles: [", err: "failed to parse profiles"},
+		"no address":      {contents: "profiles:\n  dev:\n    role: dev\n", err: `profile "dev" has no address`},
+		"empty profile":   {contents: "profiles:\n  dev:\n", err: `profile "dev" in`},
+		"path separators": {contents: "profiles:\n  ../dev:\n    address: https://vault\n", err: `profile name "../dev"`},
+		"json suffix": {
+			contents: "profiles:\n  dev:\n    address: https://vault\n  dev.json:\n    address: https://vault\n",
+			err:      `profile name "dev.json" may not end in '.json'`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "profiles.yaml")
+			assert.NilError(t, os.WriteFile(path, []byte(test.contents), 0o600))
+			t.Setenv(ProfilesPathEnv, path)
+
+			_, err := LoadProfiles()
+			assert.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestProfileVaultCLIArgs(t *testing.T) {
+	p := &Profile{Address: "https://vault", AuthMethod: "userpass"}
+	assert.DeepEqual(t, []string{"login", "-format", "json", "-method", "userpass", "-address", "https://vault"}, p.vaultCLIArgs())
+
+	p = &Profile{
+		Name:      "dev",
+		Address:   "https://vault",
+		Namespace: "team",
+		Mount:     "okta",
+		Role:      "engineer",
+		TLS:       &ProfileTLS{CACert: "/ca.pem", ServerName: "vault.internal", Insecure: true},
+	}
+	assert.DeepEqual(t, []string{
+		"login", "-format", "json", "-method", "oidc", "-address", "https://vault", "-namespace", "team", "-path", "okta",
+		"-ca-cert", "/ca.pem", "-tls-server-name", "vault.internal", "-tls-skip-verify", "-no-store", "role=engineer",
+	}, p.vaultCLIArgs())
+}
@@ -0,0 +1,197 @@
+// Copyright 2023 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to find and persist the user's Vault token
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	vault "github.com/getoutreach/vault-client"
+	"github.com/getoutreach/vault-client/internal/fileutil"
+	"github.com/pkg/errors"
+)
+
+// tokenEnv is the environment variable the vault CLI reads the token from
+const tokenEnv = "VAULT_TOKEN"
+
+// tokenInfoPath is the path, relative to the home directory, of the file
+// describing the last token obtained by EnsureLoggedIn for an unnamed profile
+var tokenInfoPath = filepath.Join(".config", "vault-client", "token.json")
+
+// profileTokensDir is the directory, relative to the home directory, the
+// tokens of named profiles are cached in. The token of a profile is stored
+// in a file named after the profile, next to a <name>.json tokenInfo file.
+var profileTokensDir = filepath.Join(".config", "vault-client", "tokens")
+
+// tokenSource is a place the user's token is read from
+type tokenSource struct {
+	// name describes the source, e.g. VAULT_TOKEN
+	name string
+
+	// get returns the token, it's empty if there is none
+	get func(ctx context.Context) (cfg.SecretData, error)
+}
+
+// tokenInfo describes a token obtained by EnsureLoggedIn, it's used to tell
+// why Vault rejects the token later on.
+type tokenInfo struct {
+	// TokenSHA256 is the hash of the token this describes
+	TokenSHA256 string `json:"token_sha256"`
+
+	// Address is the address of the Vault server that issued the token
+	Address string `json:"address"`
+
+	// ExpiresAt is when the token expires, zero if it doesn't
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// findTokenSource returns where the token of p is read from. Named profiles
+// read their own token cache, otherwise the token is read in the same order
+// the vault CLI does: VAULT_TOKEN, then the configured token helper, which
+// defaults to ~/.vault-token.
+func findTokenSource(p *Profile) (*tokenSource, error) {
+	if p.Name != "" {
+		path, err := homePath(filepath.Join(profileTokensDir, p.Name))
+		if err != nil {
+			return nil, err
+		}
+		return &tokenSource{name: path, get: func(context.Context) (cfg.SecretData, error) {
+			b, err := os.ReadFile(path)
+			if os.IsNotExist(err) {
+				return "", nil
+			}
+			return cfg.SecretData(strings.TrimSpace(string(b))), err
+		}}, nil
+	}
+
+	if token := os.Getenv(tokenEnv); token != "" {
+		return &tokenSource{name: tokenEnv, get: func(context.Context) (cfg.SecretData, error) {
+			return cfg.SecretData(token), nil
+		}}, nil
+	}
+
+	helper := newTokenHelper(p.Address)
+	return &tokenSource{name: helper.Path(), get: helper.Get}, nil
+}
+
+// newTokenHelper returns the token helper the vault CLI is configured with,
+// for tokens of the provided Vault server
+func newTokenHelper(vaultAddress string) *vault.TokenHelperAuthMethod {
+	helper := vault.NewTokenHelperAuthMethod(nil)
+	helper.Options(&vault.Options{Host: vaultAddress})
+	return helper
+}
+
+// readToken returns the token of src, or nil if there is none
+func (src *tokenSource) readToken(ctx context.Context) ([]byte, error) {
+	token, err := src.get(ctx)
+	if err != nil || token == "" {
+		return nil, err
+	}
+	return []byte(token), nil
+}
+
+// lookupToken looks up token at the Vault server of p
+func lookupToken(ctx context.Context, p *Profile, token []byte) (*vault.LookupTokenResponse, error) {
+	return vault.New(append(p.clientOpts(), vault.WithTokenAuth(cfg.SecretData(token)))...).LookupCurrentToken(ctx)
+}
+
+// saveToken persists token, in the token cache of a named profile or with
+// the configured token helper, by default to ~/.vault-token, and records
+// where it was issued.
+func saveToken(ctx context.Context, p *Profile, token []byte, expiresAt time.Time) error {
+	if p.Name != "" {
+		path, err := homePath(filepath.Join(profileTokensDir, p.Name))
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return errors.Wrap(err, "failed to create token cache directory")
+		}
+
+		if err := fileutil.WriteFileAtomic(path, token); err != nil {
+			return errors.Wrapf(err, "failed to store vault token of profile %q", p.Name)
+		}
+	} else if err := newTokenHelper(p.Address).Store(ctx, cfg.SecretData(token)); err != nil {
+		return errors.Wrap(err, "failed to store vault token")
+	}
+	return saveTokenInfo(p, token, expiresAt)
+}
+
+// tokenInfoFile returns the path, relative to the home directory, of the
+// tokenInfo of p's token
+func tokenInfoFile(p *Profile) string {
+	if p.Name == "" {
+		return tokenInfoPath
+	}
+	return filepath.Join(profileTokensDir, p.Name+".json")
+}
+
+// saveTokenInfo records where the token of p was issued and when it expires
+func saveTokenInfo(p *Profile, token []byte, expiresAt time.Time) error {
+	path, err := homePath(tokenInfoFile(p))
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(&tokenInfo{TokenSHA256: tokenHash(token), Address: normalizeAddress(p.Address), ExpiresAt: expiresAt})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode token info")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrap(err, "failed to create config directory")
+	}
+	return errors.Wrap(fileutil.WriteFileAtomic(path, b), "failed to write token info")
+}
+
+// loadTokenInfo returns the recorded information about the token of p, or
+// nil if there is none
+func loadTokenInfo(p *Profile, token []byte) *tokenInfo {
+	path, err := homePath(tokenInfoFile(p))
+	if err != nil {
+		return nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var info tokenInfo
+	if err := json.Unmarshal(b, &info); err != nil || info.TokenSHA256 != tokenHash(token) {
+		return nil
+	}
+	return &info
+}
+
+// homePath returns the absolute path of rel, a path relative to the home
+// directory
+func homePath(rel string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find home directory")
+	}
+	return filepath.Join(home, rel), nil
+}
+
+// tokenHash returns the hash of token, so it can be recognized without
+// storing it
+func tokenHash(token []byte) string {
+	sum := sha256.Sum256(token)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeAddress normalizes a Vault address so it can be compared
+func normalizeAddress(vaultAddress string) string {
+	return strings.TrimRight(vaultAddress, "/")
+}
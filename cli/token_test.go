@@ -0,0 +1,114 @@
+// Copyright 2023 Outreach Corporation. All Rights Reserved.
+
+// Description: tests finding and persisting the user's Vault token
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSaveToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("VAULT_CONFIG_PATH", "")
+
+	path := filepath.Join(home, ".vault-token")
+	assert.NilError(t, os.WriteFile(path, []byte("old"), 0o644))
+
+	p := &Profile{Address: DevelopmentAddress}
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	assert.NilError(t, saveToken(context.Background(), p, []byte("new"), expiresAt))
+
+	b, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, "new", string(b))
+
+	fi, err := os.Stat(path)
+	assert.NilError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(home)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(entries), "expected only the token file and config directory, got %v", entries)
+
+	info := loadTokenInfo(p, []byte("new"))
+	assert.Assert(t, info != nil)
+	assert.Equal(t, "https://vault-dev.outreach.cloud", info.Address)
+	assert.Assert(t, info.ExpiresAt.Equal(expiresAt))
+
+	assert.Assert(t, loadTokenInfo(p, []byte("old")) == nil, "expected no info for another token")
+}
+
+func TestSaveTokenForProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	p := &Profile{Name: "dev", Address: DevelopmentAddress}
+	assert.NilError(t, saveToken(context.Background(), p, []byte("token"), time.Time{}))
+
+	path := filepath.Join(home, profileTokensDir, "dev")
+	b, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, "token", string(b))
+
+	fi, err := os.Stat(path)
+	assert.NilError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// the token info is recorded per profile
+	assert.Assert(t, loadTokenInfo(p, []byte("token")) != nil)
+	assert.Assert(t, loadTokenInfo(&Profile{Address: DevelopmentAddress}, []byte("token")) == nil)
+	assert.Assert(t, loadTokenInfo(&Profile{Name: "prod", Address: ProductionAddress}, []byte("token")) == nil)
+}
+
+func TestFindTokenSourceOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake token helper is a shell script")
+	}
+
+	ctx := context.Background()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(tokenEnv, "")
+	t.Setenv("VAULT_CONFIG_PATH", filepath.Join(home, "missing.hcl"))
+	p := &Profile{Address: DevelopmentAddress}
+
+	read := func() (string, string) {
+		src, err := findTokenSource(p)
+		assert.NilError(t, err)
+
+		token, err := src.readToken(ctx)
+		assert.NilError(t, err)
+		return string(token), src.name
+	}
+
+	// without a token helper, ~/.vault-token is used
+	tokenFile := filepath.Join(home, ".vault-token")
+	assert.NilError(t, os.WriteFile(tokenFile, []byte("file"), 0o600))
+	token, name := read()
+	assert.Equal(t, "file", token)
+	assert.Equal(t, tokenFile, name)
+
+	// the configured token helper replaces ~/.vault-token
+	helper := filepath.Join(home, "helper")
+	assert.NilError(t, os.WriteFile(helper, []byte("#!/bin/sh\n[ \"$1\" = get ] && echo helper\n"), 0o700))
+	assert.NilError(t, os.WriteFile(filepath.Join(home, "config.hcl"), []byte(`token_helper = "`+helper+`"`), 0o600))
+	t.Setenv("VAULT_CONFIG_PATH", filepath.Join(home, "config.hcl"))
+	token, name = read()
+	assert.Equal(t, "helper", token)
+	assert.Equal(t, helper, name)
+
+	// VAULT_TOKEN takes precedence over everything
+	t.Setenv(tokenEnv, "env")
+	token, name = read()
+	assert.Equal(t, "env", token)
+	assert.Equal(t, tokenEnv, name)
+}
@@ -0,0 +1,181 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements the vault-client commands
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/getoutreach/vault-client/cli"
+	"github.com/pkg/errors"
+)
+
+// kvGetFlags registers the flags of kv get
+func kvGetFlags(fs *flag.FlagSet, a *app) {
+	fs.StringVar(&a.field, "field", "", "only output the value of this key of the secret")
+}
+
+// kvGet implements kv get
+func kvGet(ctx context.Context, a *app, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, usageError("expected <engine> <path>")
+	}
+
+	secret, err := a.client(true).GetKV2Secret(ctx, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
+// kvPut implements kv put
+func kvPut(ctx context.Context, a *app, args []string) (interface{}, error) {
+	if len(args) < 3 {
+		return nil, usageError("expected <engine> <path> <key=value>...")
+	}
+
+	data := make(map[string]interface{}, len(args)-2)
+	for _, kv := range args[2:] {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, usageError("expected key=value, got " + kv)
+		}
+		data[k] = v
+	}
+
+	return nil, a.client(true).CreateKV2Secret(ctx, args[0], args[1], data)
+}
+
+// kvList implements kv list
+func kvList(ctx context.Context, a *app, args []string) (interface{}, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, usageError("expected <engine> [path]")
+	}
+
+	keyPath := ""
+	if len(args) == 2 {
+		keyPath = args[1]
+	}
+
+	keys, err := a.client(true).ListKV2Secrets(ctx, args[0], keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// always output a list, even if there are no secrets
+	if keys == nil {
+		keys = []string{}
+	}
+	return keys, nil
+}
+
+// transitEncrypt implements transit encrypt
+func transitEncrypt(ctx context.Context, a *app, args []string) (interface{}, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, usageError("expected <key> [plaintext]")
+	}
+
+	var plaintext []byte
+	if len(args) == 2 {
+		plaintext = []byte(args[1])
+	} else {
+		var err error
+		if plaintext, err = io.ReadAll(a.stdin); err != nil {
+			return nil, errors.Wrap(err, "failed to read plaintext from stdin")
+		}
+	}
+
+	ciphertext, err := a.client(true).TransitEncrypt(ctx, args[0], plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"ciphertext": string(ciphertext)}, nil
+}
+
+// transitDecrypt implements transit decrypt
+func transitDecrypt(ctx context.Context, a *app, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, usageError("expected <key> <ciphertext>")
+	}
+
+	plaintext, err := a.client(true).TransitDecrypt(ctx, args[0], []byte(args[1]))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"plaintext": string(plaintext)}, nil
+}
+
+// tokenLookup implements token lookup
+func tokenLookup(ctx context.Context, a *app, args []string) (interface{}, error) {
+	switch len(args) {
+	case 0:
+		return a.client(true).LookupCurrentToken(ctx)
+	case 1:
+		return a.client(true).LookupToken(ctx, cfg.SecretData(args[0]))
+	default:
+		return nil, usageError("expected [token]")
+	}
+}
+
+// status implements status, like the vault CLI it exits with 2 if Vault is
+// sealed
+func status(ctx context.Context, a *app, args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, usageError("expected no arguments")
+	}
+
+	health, err := a.client(false).Health(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if health.Sealed {
+		a.exitCode = exitSealed
+	}
+	return health, nil
+}
+
+// loginFlags registers the flags of login
+func loginFlags(fs *flag.FlagSet, a *app) {
+	fs.StringVar(&a.profile, "profile", "", "profile to log into, see ~/.config/vault-client/profiles.yaml")
+	fs.StringVar(&a.method, "method", cli.OidcAuthMethod, "auth method to log in with")
+	fs.DurationVar(&a.minRemaining, "min-remaining", 0, "log in again if the current token expires within this duration")
+}
+
+// login implements login
+func login(ctx context.Context, a *app, args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, usageError("expected no arguments")
+	}
+
+	// without a profile, log into the Vault server of the global flags and
+	// store the token with the token helper, like the vault CLI
+	p := &cli.Profile{Address: a.address, Namespace: a.namespace, AuthMethod: a.method}
+	if a.profile != "" {
+		var err error
+		if p, err = cli.LoadProfile(a.profile); err != nil {
+			return nil, err
+		}
+	} else if a.address == "" {
+		return nil, usageError("no Vault address, set -address, VAULT_ADDR or -profile")
+	}
+
+	_, expiresAt, err := cli.EnsureLoggedInWithProfile(ctx, p, a.minRemaining)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]string{"address": p.Address, "expires_at": "never"}
+	if p.Name != "" {
+		result["profile"] = p.Name
+	}
+	if !expiresAt.IsZero() {
+		result["expires_at"] = expiresAt.Format(time.RFC3339)
+	}
+	return result, nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	vault "github.com/getoutreach/vault-client"
+	"github.com/getoutreach/vault-client/cli"
+	"github.com/getoutreach/vault-client/pkg/vaulttest"
+)
+
+// setupTestVault starts an in-memory Vault with a kv2 engine at deploy and
+// points vault-client at it
+func setupTestVault(t *testing.T) {
+	t.Helper()
+
+	host, token, cleanup := vaulttest.NewInMemoryServer(t, false)
+	t.Cleanup(cleanup)
+
+	t.Setenv("VAULT_ADDR", host)
+	t.Setenv("VAULT_TOKEN", string(token))
+	t.Setenv("VAULT_NAMESPACE", "")
+
+	c := vault.New(vault.WithAddress(host), vault.WithTokenAuth(token))
+	if err := c.CreateEngine(context.Background(), "deploy", &vault.CreateEngineOptions{
+		Type:    "kv",
+		Options: map[string]interface{}{"version": 2},
+	}); err != nil {
+		t.Fatalf("Failed to create a kv2 engine: CreateEngine() = %v", err)
+	}
+}
+
+func TestKV(t *testing.T) {
+	setupTestVault(t)
+
+	if code, _, stderr := runTest(t, "", "kv", "put", "deploy", "app/config", "user=admin", "password=hunter2"); code != exitOK {
+		t.Errorf("kv put: expected success, got %d: %s", code, stderr)
+		return
+	}
+
+	code, stdout, stderr := runTest(t, "", "-format", "json", "kv", "get", "deploy", "app/config")
+	if code != exitOK {
+		t.Errorf("kv get: expected success, got %d: %s", code, stderr)
+		return
+	}
+
+	var data map[string]string
+	if err := json.Unmarshal([]byte(stdout), &data); err != nil || data["user"] != "admin" || data["password"] != "hunter2" {
+		t.Errorf("kv get: unexpected output %q, %v", stdout, err)
+	}
+
+	if code, stdout, _ := runTest(t, "", "kv", "get", "-field", "password", "deploy", "app/config"); code != exitOK || stdout != "hunter2\n" {
+		t.Errorf("kv get -field: expected the raw value, got %d: %q", code, stdout)
+	}
+
+	if code, _, stderr := runTest(t, "", "kv", "get", "deploy", "missing"); code != exitError || !strings.Contains(stderr, "404") {
+		t.Errorf("kv get: expected an error for a missing secret, got %d: %s", code, stderr)
+	}
+
+	if code, _, _ := runTest(t, "", "kv", "put", "deploy", "app/config", "invalid"); code != exitUsage {
+		t.Errorf("kv put: expected usage for an invalid key=value, got %d", code)
+	}
+}
+
+// TestKVList uses a fake Vault, the in-memory Vault only supports kv1 engines
+// which can't be listed through kv2 metadata
+func TestKVList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "LIST" && r.URL.Path == "/v1/deploy/metadata/app":
+			w.Write([]byte(`{"data":{"keys":["config","db/"]}}`)) //nolint:errcheck // Why: test server
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("VAULT_TOKEN", "token")
+	t.Setenv("VAULT_NAMESPACE", "")
+
+	if code, stdout, stderr := runTest(t, "", "-address", srv.URL, "kv", "list", "-format", "yaml", "deploy", "app"); code != exitOK ||
+		stdout != "- config\n- db/\n" {
+		t.Errorf("kv list: expected the keys, got %d: %q %s", code, stdout, stderr)
+	}
+
+	if code, _, _ := runTest(t, "", "-address", srv.URL, "kv", "list", "deploy", "missing"); code != exitError {
+		t.Errorf("kv list: expected an error for a missing path, got %d", code)
+	}
+}
+
+func TestTokenLookupAndStatus(t *testing.T) {
+	setupTestVault(t)
+
+	code, stdout, stderr := runTest(t, "", "token", "lookup")
+	if code != exitOK || !strings.Contains(stdout, "policies") || !strings.Contains(stdout, "[root]") {
+		t.Errorf("token lookup: expected the root token, got %d: %s%s", code, stdout, stderr)
+	}
+
+	if code, _, _ := runTest(t, "", "token", "lookup", "unknown"); code != exitError {
+		t.Errorf("token lookup: expected an error for an unknown token, got %d", code)
+	}
+
+	// status doesn't need a token
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_CONFIG_PATH", t.TempDir()+"/missing")
+	t.Setenv("HOME", t.TempDir())
+
+	code, stdout, stderr = runTest(t, "", "-format", "json", "status")
+	if code != exitOK {
+		t.Errorf("status: expected success, got %d: %s", code, stderr)
+		return
+	}
+
+	var health vault.HealthResponse
+	if err := json.Unmarshal([]byte(stdout), &health); err != nil || !health.Initialized || health.Sealed {
+		t.Errorf("status: expected an unsealed Vault, got %q, %v", stdout, err)
+	}
+}
+
+func TestTransit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req) //nolint:errcheck // Why: test server
+
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/app":
+			fmt.Fprintf(w, `{"data":{"ciphertext":"vault:v1:%s"}}`, req["plaintext"])
+		case "/v1/transit/decrypt/app":
+			fmt.Fprintf(w, `{"data":{"plaintext":%q}}`, strings.TrimPrefix(req["ciphertext"], "vault:v1:"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("VAULT_TOKEN", "token")
+	t.Setenv("VAULT_NAMESPACE", "")
+
+	code, stdout, stderr := runTest(t, "secret", "-address", srv.URL, "transit", "encrypt", "-format", "json", "app")
+	if code != exitOK {
+		t.Errorf("transit encrypt: expected success, got %d: %s", code, stderr)
+		return
+	}
+
+	var resp map[string]string
+	if err := json.Unmarshal([]byte(stdout), &resp); err != nil || resp["ciphertext"] != "vault:v1:c2VjcmV0" {
+		t.Errorf("transit encrypt: expected stdin to be encrypted, got %q, %v", stdout, err)
+	}
+
+	code, stdout, stderr = runTest(t, "", "-address", srv.URL, "transit", "decrypt", "app", resp["ciphertext"])
+	if code != exitOK || !strings.Contains(stdout, "plaintext  secret") {
+		t.Errorf("transit decrypt: expected the plaintext, got %d: %s%s", code, stdout, stderr)
+	}
+}
+
+func TestLoginProfile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/token/lookup-self" || r.Header.Get("Authorization") != "Bearer cached" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"id":"cached"}}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(cli.ProfilesPathEnv, filepath.Join(home, "profiles.yaml"))
+
+	profiles := "profiles:\n  dev:\n    address: " + srv.URL + "\n"
+	if err := os.WriteFile(filepath.Join(home, "profiles.yaml"), []byte(profiles), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := filepath.Join(home, ".config", "vault-client", "tokens")
+	if err := os.MkdirAll(tokens, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tokens, "dev"), []byte("cached"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runTest(t, "", "-format", "json", "login", "-profile", "dev")
+	if code != exitOK {
+		t.Errorf("login: expected success with the cached token, got %d: %s", code, stderr)
+		return
+	}
+
+	var result map[string]string
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil || result["profile"] != "dev" || result["expires_at"] != "never" {
+		t.Errorf("login: unexpected output %q, %v", stdout, err)
+	}
+
+	if code, _, stderr := runTest(t, "", "login", "-profile", "unknown"); code != exitError || !strings.Contains(stderr, "unknown profile") {
+		t.Errorf("login: expected an error for an unknown profile, got %d: %s", code, stderr)
+	}
+}
+
+func TestStatusSealed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"initialized":true,"sealed":true}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	code, stdout, stderr := runTest(t, "", "-address", srv.URL, "-format", "json", "status")
+	if code != exitSealed || !strings.Contains(stdout, `"sealed": true`) {
+		t.Errorf("status: expected a sealed Vault, got %d: %s%s", code, stdout, stderr)
+	}
+}
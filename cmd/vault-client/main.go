@@ -0,0 +1,225 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements the vault-client command-line tool
+
+// Package main implements vault-client, a command-line tool for Vault built
+// on the vault-client library, so scripts talk to Vault exactly the way our
+// services do.
+//
+//	vault-client [flags] <command> [flags] [args]
+//
+// Authentication is read from the environment like vault_client.WithEnv,
+// falling back to the token stored by the vault CLI's token helper.
+//
+// vault-client exits with 0 on success, 1 if the command failed, 2 if
+// status found Vault sealed and 64 if it was invoked incorrectly.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	vault "github.com/getoutreach/vault-client"
+)
+
+// exitCodes returned by run, they don't overlap so scripts can tell them
+// apart
+const (
+	// exitOK is returned if the command succeeded
+	exitOK = 0
+
+	// exitError is returned if the command failed
+	exitError = 1
+
+	// exitSealed is returned by status if Vault is sealed, it matches the
+	// vault CLI
+	exitSealed = 2
+
+	// exitUsage is returned if vault-client was invoked incorrectly, it's
+	// EX_USAGE from sysexits.h
+	exitUsage = 64
+)
+
+// command is a vault-client subcommand
+type command struct {
+	// usage is the usage of the command's arguments
+	usage string
+
+	// description describes the command
+	description string
+
+	// flags, if set, registers the command's flags
+	flags func(fs *flag.FlagSet, a *app)
+
+	// run runs the command and returns the result to output, if any
+	run func(ctx context.Context, a *app, args []string) (interface{}, error)
+}
+
+// commands are the subcommands of vault-client, nested subcommands are
+// joined with a space
+var commands = map[string]*command{
+	"kv get":          {usage: "<engine> <path>", description: "Reads a secret from a kv2 engine", flags: kvGetFlags, run: kvGet},
+	"kv put":          {usage: "<engine> <path> <key=value>...", description: "Writes a secret to a kv2 engine", run: kvPut},
+	"kv list":         {usage: "<engine> [path]", description: "Lists the secrets of a kv2 engine", run: kvList},
+	"transit encrypt": {usage: "<key> [plaintext]", description: "Encrypts plaintext, or stdin, with a transit key", run: transitEncrypt},
+	"transit decrypt": {usage: "<key> <ciphertext>", description: "Decrypts ciphertext with a transit key", run: transitDecrypt},
+	"token lookup":    {usage: "[token]", description: "Looks up the current, or the provided, token", run: tokenLookup},
+	"status":          {description: "Returns the health of the Vault server", run: status},
+	"login":           {description: "Logs into Vault, or a profile's Vault, and stores the token", flags: loginFlags, run: login},
+}
+
+// app is the state shared by all commands
+type app struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+
+	// address, namespace and format are set by the global flags
+	address   string
+	namespace string
+	format    string
+
+	// field, if set, only outputs this field of the result
+	field string
+
+	// profile, method and minRemaining are the flags of the login command
+	profile      string
+	method       string
+	minRemaining time.Duration
+
+	// exitCode overrides the exit code of a successful command
+	exitCode int
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	cancel()
+	os.Exit(code)
+}
+
+// run runs vault-client with the provided arguments and returns its exit
+// code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	a := &app{stdin: stdin, stdout: stdout, stderr: stderr, address: os.Getenv("VAULT_ADDR"), format: formatTable}
+
+	fs := a.flagSet("vault-client")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	name, cmd, args := findCommand(fs.Args())
+	if cmd == nil {
+		a.usage()
+		return exitUsage
+	}
+
+	fs = a.flagSet("vault-client " + name)
+	if cmd.flags != nil {
+		cmd.flags(fs, a)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	// the format is checked before the command runs, it may have made
+	// changes by the time its result is written
+	if !validFormat(a.format) {
+		fmt.Fprintf(a.stderr, "Error: unknown format %q, expected table, json or yaml\n", a.format)
+		return exitUsage
+	}
+	return a.runCommand(ctx, name, cmd, fs.Args())
+}
+
+// runCommand runs cmd and writes its result
+func (a *app) runCommand(ctx context.Context, name string, cmd *command, args []string) int {
+	result, err := cmd.run(ctx, a, args)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "Error: %s: %v\n", name, err)
+		if _, ok := err.(usageError); ok {
+			return exitUsage
+		}
+		return exitError
+	}
+
+	if result != nil {
+		if err := a.output(result); err != nil {
+			fmt.Fprintf(a.stderr, "Error: failed to write output: %v\n", err)
+			return exitError
+		}
+	}
+	return a.exitCode
+}
+
+// flagSet returns a new flag.FlagSet with the global flags, so they can be
+// provided before or after the command.
+func (a *app) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&a.address, "address", a.address, "address of the Vault server, defaults to VAULT_ADDR")
+	fs.StringVar(&a.namespace, "namespace", a.namespace, "Vault namespace, defaults to VAULT_NAMESPACE")
+	fs.StringVar(&a.format, "format", a.format, "output format: table, json or yaml")
+	fs.Usage = a.usage
+	return fs
+}
+
+// usage writes the usage of vault-client
+func (a *app) usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(a.stderr, "Usage: vault-client [-address addr] [-namespace ns] [-format table|json|yaml] <command> [args]")
+	fmt.Fprintln(a.stderr, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(a.stderr, "  %-40s %s\n", strings.TrimSpace(name+" "+commands[name].usage), commands[name].description)
+	}
+}
+
+// findCommand returns the command named by the leading args, and the
+// remaining arguments. The command is nil if there is none.
+func findCommand(args []string) (string, *command, []string) {
+	for n := len(args); n > 0; n-- {
+		name := strings.Join(args[:n], " ")
+		if cmd, ok := commands[name]; ok {
+			return name, cmd, args[n:]
+		}
+	}
+	return "", nil, nil
+}
+
+// usageError is returned by commands that were called with invalid
+// arguments
+type usageError string
+
+// Error implements error
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// client returns a Vault client configured from the environment and the
+// global flags, authenticated if auth is true
+func (a *app) client(auth bool) *vault.Client {
+	opts := []vault.Opts{vault.WithEnvAuthPrecedence()}
+	if auth {
+		opts[0] = vault.WithEnvAuthPrecedence(vault.EnvAuthToken, vault.EnvAuthApprole, vault.EnvAuthKubernetes, vault.EnvAuthTokenHelper)
+	}
+
+	if a.address != "" {
+		opts = append(opts, vault.WithAddress(a.address))
+	}
+
+	if a.namespace != "" {
+		opts = append(opts, vault.WithNamespace(a.namespace))
+	}
+	return vault.New(opts...)
+}
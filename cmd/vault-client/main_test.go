@@ -0,0 +1,83 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// runTest runs vault-client with args and returns its exit code, stdout
+// and stderr
+func runTest(t *testing.T, stdin string, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+
+	var out, errOut bytes.Buffer
+	code = run(context.Background(), args, strings.NewReader(stdin), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+
+	if code, _, stderr := runTest(t, ""); code != exitUsage || !strings.Contains(stderr, "kv get <engine> <path>") {
+		t.Errorf("run(): expected usage without a command, got %d: %s", code, stderr)
+	}
+
+	if code, _, _ := runTest(t, "", "kv", "delete"); code != exitUsage {
+		t.Errorf("run(): expected usage for an unknown command, got %d", code)
+	}
+
+	if code, _, stderr := runTest(t, "", "kv", "get", "secret"); code != exitUsage || !strings.Contains(stderr, "expected <engine> <path>") {
+		t.Errorf("run(): expected usage for missing arguments, got %d: %s", code, stderr)
+	}
+
+	if code, _, _ := runTest(t, "", "status", "-unknown"); code != exitUsage {
+		t.Errorf("run(): expected usage for an unknown flag, got %d", code)
+	}
+
+	if code, _, stderr := runTest(t, "", "login"); code != exitUsage || !strings.Contains(stderr, "no Vault address") {
+		t.Errorf("run(): expected usage for login without an address, got %d: %s", code, stderr)
+	}
+}
+
+func TestRun_InvalidFormat(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "token")
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// the format may be provided before or after the command
+	for _, args := range [][]string{
+		{"-address", srv.URL, "-format", "xml", "kv", "put", "secret", "foo", "hello=world"},
+		{"-address", srv.URL, "kv", "put", "-format", "xml", "secret", "foo", "hello=world"},
+	} {
+		code, _, stderr := runTest(t, "", args...)
+		if code != exitUsage || !strings.Contains(stderr, `unknown format "xml"`) {
+			t.Errorf("run(%v): expected usage for an unknown format, got %d: %s", args, code, stderr)
+		}
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 0 {
+		t.Errorf("run(): expected the command not to run with an unknown format, got %d requests", got)
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	name, cmd, args := findCommand([]string{"kv", "get", "-field", "key", "secret", "path"})
+	if name != "kv get" || cmd == nil || strings.Join(args, " ") != "-field key secret path" {
+		t.Errorf("findCommand(): unexpected command %q with args %v", name, args)
+	}
+
+	if _, cmd, _ := findCommand([]string{"kv"}); cmd != nil {
+		t.Error("findCommand(): expected no command for a command group")
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements the output formats of vault-client
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// output formats supported by vault-client
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// validFormat returns true if format is one of the supported output formats
+func validFormat(format string) bool {
+	switch format {
+	case formatTable, formatJSON, formatYAML:
+		return true
+	default:
+		return false
+	}
+}
+
+// output writes result in the configured format
+func (a *app) output(result interface{}) error {
+	v, err := normalize(result)
+	if err != nil {
+		return err
+	}
+
+	if a.field != "" {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return errors.New("result has no fields")
+		}
+
+		field, ok := m[a.field]
+		if !ok {
+			return errors.Errorf("field %q not found", a.field)
+		}
+
+		_, err := fmt.Fprintln(a.stdout, formatValue(field))
+		return err
+	}
+
+	switch a.format {
+	case formatJSON:
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatYAML:
+		enc := yaml.NewEncoder(a.stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	case formatTable:
+		return writeTable(a, v)
+	default:
+		return errors.Errorf("unknown format %q, expected table, json or yaml", a.format)
+	}
+}
+
+// normalize converts result into the generic values it's JSON encoded as,
+// so every format outputs the same keys. Numbers are kept as integers
+// where possible.
+func normalize(result interface{}) (interface{}, error) {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode result")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "failed to decode result")
+	}
+	return normalizeNumbers(v), nil
+}
+
+// normalizeNumbers replaces the json.Numbers in v with int64s, or float64s
+// if they're not integers
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64() //nolint:errcheck // Why: json.Number is always a valid float
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeNumbers(e)
+		}
+	}
+	return v
+}
+
+// writeTable writes v as a table: objects as key/value rows sorted by key
+// and lists as one row per element.
+func writeTable(a *app, v interface{}) error {
+	tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintln(tw, "Key\tValue")
+		fmt.Fprintln(tw, "---\t-----")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", k, formatValue(v[k]))
+		}
+	case []interface{}:
+		for _, e := range v {
+			fmt.Fprintln(tw, formatValue(e))
+		}
+	default:
+		fmt.Fprintln(tw, formatValue(v))
+	}
+
+	return tw.Flush()
+}
+
+// formatValue formats a single value for the table format
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "n/a"
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestOutput(t *testing.T) {
+	result := struct {
+		Name     string      `json:"name"`
+		TTL      int         `json:"ttl"`
+		Created  time.Time   `json:"created"`
+		Policies []string    `json:"policies"`
+		Meta     interface{} `json:"meta"`
+	}{
+		Name:     "token",
+		TTL:      1676411158,
+		Created:  time.Date(2023, 2, 14, 21, 45, 58, 0, time.UTC),
+		Policies: []string{"default", "dev"},
+	}
+
+	tests := map[string]struct {
+		format string
+		field  string
+		want   string
+	}{
+		"table": {
+			format: formatTable,
+			want: `Key       Value
+---       -----
+created   2023-02-14T21:45:58Z
+meta      n/a
+name      token
+policies  [default dev]
+ttl       1676411158
+`,
+		},
+		"json": {
+			format: formatJSON,
+			want: `{
+  "created": "2023-02-14T21:45:58Z",
+  "meta": null,
+  "name": "token",
+  "policies": [
+    "default",
+    "dev"
+  ],
+  "ttl": 1676411158
+}
+`,
+		},
+		"yaml": {
+			format: formatYAML,
+			want: `created: "2023-02-14T21:45:58Z"
+meta: null
+name: token
+policies:
+  - default
+  - dev
+ttl: 1676411158
+`,
+		},
+		"field": {
+			format: formatJSON,
+			field:  "name",
+			want:   "token\n",
+		},
+	}
+
+	for name, test := range tests {
+		var out bytes.Buffer
+		a := &app{stdout: &out, format: test.format, field: test.field}
+		if err := a.output(result); err != nil {
+			t.Errorf("%s: output() = %v", name, err)
+			continue
+		}
+
+		if out.String() != test.want {
+			t.Errorf("%s: output(): expected\n%s\ngot\n%s", name, test.want, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	a := &app{stdout: &out, format: formatTable}
+	if err := a.output([]string{"a/", "b"}); err != nil || out.String() != "a/\nb\n" {
+		t.Errorf("output(): expected a row per element, got %q, %v", out.String(), err)
+	}
+
+	a = &app{stdout: &out, format: "xml"}
+	if err := a.output(result); err == nil {
+		t.Error("output(): expected an error for an unknown format")
+	}
+
+	a = &app{stdout: &out, format: formatTable, field: "missing"}
+	if err := a.output(result); err == nil {
+		t.Error("output(): expected an error for a missing field")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores the error types returned by the Vault client
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorResponse is returned when an error occurs
+type ErrorResponse struct {
+	// Errors is a list of errors that were encountered when Vault tried
+	// to process this request.
+	Errors []string `json:"errors"`
+
+	// Warnings is a list of warnings that Vault returned alongside the
+	// errors, if any.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ResponseError is returned by every Client method when Vault responds
+// with a non-successful status code. Use errors.As to get at it, or one
+// of the Is* helpers in this file.
+type ResponseError struct {
+	// StatusCode is the HTTP status code returned by Vault
+	StatusCode int
+
+	// Method is the HTTP method that was used for the request
+	Method string
+
+	// Endpoint is the logical path (without the /v1/ prefix) that
+	// was requested, e.g. auth/token/lookup-self
+	Endpoint string
+
+	// Errors is the list of errors returned by Vault, this may be
+	// empty, e.g. for a 404 on a KV path.
+	Errors []string
+
+	// Warnings is the list of warnings returned by Vault, if any
+	Warnings []string
+}
+
+// Error implements the error interface
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("vault: %s %s returned %d", e.Method, e.Endpoint, e.StatusCode)
+	if len(e.Errors) != 0 {
+		msg += ": " + strings.Join(e.Errors, "; ")
+	}
+	return msg
+}
+
+// responseError returns the *ResponseError contained in err, if there is one
+func responseError(err error) (*ResponseError, bool) {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return respErr, true
+	}
+	return nil, false
+}
+
+// StatusCode returns the HTTP status code of the *ResponseError contained
+// in err, or 0 if err doesn't contain one.
+func StatusCode(err error) int {
+	if respErr, ok := responseError(err); ok {
+		return respErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound returns true if err is a Vault response with a 404 status code,
+// e.g. a KV2 secret that doesn't exist.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsPermissionDenied returns true if err is a Vault response with a 403
+// status code. Vault returns this both for invalid tokens and for tokens
+// whose policies don't allow the request.
+func IsPermissionDenied(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsRateLimited returns true if err is a Vault response with a 429 status
+// code, e.g. when a rate limit quota was exceeded.
+func IsRateLimited(err error) bool {
+	return StatusCode(err) == http.StatusTooManyRequests
+}
+
+// IsSealed returns true if err is a Vault response that indicates that the
+// Vault server is sealed.
+func IsSealed(err error) bool {
+	respErr, ok := responseError(err)
+	if !ok || respErr.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+
+	for _, e := range respErr.Errors {
+		if strings.Contains(strings.ToLower(e), "sealed") {
+			return true
+		}
+	}
+	return false
+}
+
+// WrappedResponseError is returned by methods that decode the response into
+// a typed result when Vault wrapped the response, e.g. because of
+// WithWrapTTL. The response is only available by unwrapping WrapInfo.Token.
+type WrappedResponseError struct {
+	// Method is the HTTP method that was used for the request
+	Method string
+
+	// Endpoint is the logical path (without the /v1/ prefix) that
+	// was requested
+	Endpoint string
+
+	// WrapInfo is the wrap_info returned in place of the response
+	WrapInfo *WrapInfo
+}
+
+// Error implements the error interface
+func (e *WrappedResponseError) Error() string {
+	return fmt.Sprintf("vault: %s %s returned a wrapped response, unwrap it to get the response", e.Method, e.Endpoint)
+}
+
+// WrapInfoFromError returns the WrapInfo of the *WrappedResponseError
+// contained in err, or nil if err doesn't contain one.
+func WrapInfoFromError(err error) *WrapInfo {
+	var wrapErr *WrappedResponseError
+	if errors.As(err, &wrapErr) {
+		return wrapErr.WrapInfo
+	}
+	return nil
+}
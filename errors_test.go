@@ -0,0 +1,131 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestResponseError_Vault(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+
+	if err := vc.CreateEngine(ctx, "deploy", &CreateEngineOptions{
+		Type: "kv",
+		Options: map[string]interface{}{
+			"version": 2,
+		},
+	}); err != nil {
+		t.Errorf("Failed to create a kv2 engine: CreateEngine() = %v", err)
+		return
+	}
+
+	_, err := vc.GetKV2Secret(ctx, "deploy", "does-not-exist")
+	if !IsNotFound(err) {
+		t.Errorf("GetKV2Secret(): expected IsNotFound() for missing secret, got %v", err)
+	}
+
+	_, err = vc.LookupToken(ctx, "fake-token")
+	if !IsPermissionDenied(err) {
+		t.Errorf("LookupToken(): expected IsPermissionDenied() for invalid token, got %v", err)
+	}
+
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) {
+		t.Errorf("LookupToken(): expected a *ResponseError, got %T", err)
+		return
+	}
+
+	if respErr.Method != http.MethodPost || respErr.Endpoint != "auth/token/lookup" {
+		t.Errorf("LookupToken(): unexpected method/endpoint on error: %s %s", respErr.Method, respErr.Endpoint)
+	}
+}
+
+func TestResponseError_Predicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		want     *ResponseError
+		isSealed bool
+		isRate   bool
+	}{
+		{
+			name:   "sealed",
+			status: http.StatusServiceUnavailable,
+			body:   `{"errors":["Vault is sealed"]}`,
+			want: &ResponseError{
+				StatusCode: http.StatusServiceUnavailable,
+				Method:     http.MethodGet,
+				Endpoint:   "secret/data/foo",
+				Errors:     []string{"Vault is sealed"},
+			},
+			isSealed: true,
+		},
+		{
+			name:   "rate limited with warnings",
+			status: http.StatusTooManyRequests,
+			body:   `{"errors":["request path \"secret/data/foo\": rate limit quota exceeded"],"warnings":["slow down"]}`,
+			want: &ResponseError{
+				StatusCode: http.StatusTooManyRequests,
+				Method:     http.MethodGet,
+				Endpoint:   "secret/data/foo",
+				Errors:     []string{`request path "secret/data/foo": rate limit quota exceeded`},
+				Warnings:   []string{"slow down"},
+			},
+			isRate: true,
+		},
+		{
+			name:   "non-json body",
+			status: http.StatusBadGateway,
+			body:   "Bad Gateway\n",
+			want: &ResponseError{
+				StatusCode: http.StatusBadGateway,
+				Method:     http.MethodGet,
+				Endpoint:   "secret/data/foo",
+				Errors:     []string{"Bad Gateway"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body)) //nolint:errcheck // Why: test server
+			}))
+			defer srv.Close()
+
+			c := New(WithAddress(srv.URL))
+			_, err := c.GetKV2Secret(context.Background(), "secret", "foo")
+
+			// errors should survive being wrapped
+			err = pkgerrors.Wrap(err, "wrapped")
+
+			var respErr *ResponseError
+			if !errors.As(err, &respErr) {
+				t.Errorf("GetKV2Secret(): expected a *ResponseError, got %T", err)
+				return
+			}
+
+			if diff := cmp.Diff(tt.want, respErr); diff != "" {
+				t.Errorf("GetKV2Secret(): %s", diff)
+			}
+
+			if got := IsSealed(err); got != tt.isSealed {
+				t.Errorf("IsSealed() = %v, want %v", got, tt.isSealed)
+			}
+
+			if got := IsRateLimited(err); got != tt.isRate {
+				t.Errorf("IsRateLimited() = %v, want %v", got, tt.isRate)
+			}
+		})
+	}
+}
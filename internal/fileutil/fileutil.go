@@ -0,0 +1,43 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements file helpers shared by vault-client packages
+
+// Package fileutil implements file helpers shared by the vault-client
+// packages
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic replaces the file at path with b, readable only by the
+// current user. The file is written to a temporary file first, so readers
+// never see a partially written file.
+func WriteFileAtomic(path string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // Why: it's gone once renamed
+
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
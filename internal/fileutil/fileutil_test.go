@@ -0,0 +1,39 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Errorf("os.WriteFile() = %v", err)
+		return
+	}
+
+	if err := WriteFileAtomic(path, []byte("new")); err != nil {
+		t.Errorf("WriteFileAtomic() = %v", err)
+		return
+	}
+
+	if b, err := os.ReadFile(path); err != nil || string(b) != "new" {
+		t.Errorf("expected the file to be replaced, got %q, %v", b, err)
+	}
+
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected the file to only be readable by the user, got %v, %v", fi.Mode(), err)
+	}
+
+	// no temporary files are left behind
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("expected only the written file, got %v, %v", entries, err)
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "token"), []byte("new")); err == nil {
+		t.Error("WriteFileAtomic(): expected an error for a missing directory")
+	}
+}
@@ -40,8 +40,10 @@ type underlyingKV2SecretResponse struct {
 //	c.GetKV2Secret("deploy", "my/cool/secret")
 func (c *Client) GetKV2Secret(ctx context.Context, engine, keyPath string) (*KV2Secret, error) {
 	var resp underlyingKV2SecretResponse
-	err := c.doRequest(ctx, http.MethodGet, path.Join(engine, "data", keyPath), nil, &resp)
-	return &resp.Data, err
+	if err := c.doRequest(ctx, http.MethodGet, path.Join(engine, "data", keyPath), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
 }
 
 // CreateKV2Secret creates a new KV2Secret or updates it if it already exists.
@@ -0,0 +1,176 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements background renewal of a client's token
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lifetimeRetryInterval is how long the LifetimeWatcher waits before trying
+// again after failing to refresh the token.
+const lifetimeRetryInterval = 10 * time.Second
+
+// lifetimeIdleInterval is how often the LifetimeWatcher checks for a token
+// with an expiration when the current token doesn't expire.
+const lifetimeIdleInterval = time.Minute
+
+// LifetimeEventType is the type of a LifetimeEvent
+type LifetimeEventType string
+
+// Contains the types of LifetimeEvents
+const (
+	// LifetimeEventRenewed is sent when the token was renewed with renew-self
+	LifetimeEventRenewed LifetimeEventType = "renewed"
+
+	// LifetimeEventReauthenticated is sent when a new token was obtained
+	// from the auth method, e.g. because the max TTL was reached
+	LifetimeEventReauthenticated LifetimeEventType = "reauthenticated"
+
+	// LifetimeEventFailed is sent when the token couldn't be refreshed, the
+	// LifetimeWatcher will try again shortly.
+	LifetimeEventFailed LifetimeEventType = "failed"
+)
+
+// LifetimeEvent is sent by a LifetimeWatcher every time it refreshed, or
+// failed to refresh, the client's token.
+type LifetimeEvent struct {
+	// Type is the type of event
+	Type LifetimeEventType
+
+	// ExpiresAt is when the current token expires, it's zero if the token
+	// doesn't expire or couldn't be obtained.
+	ExpiresAt time.Time
+
+	// Err is set for LifetimeEventFailed events
+	Err error
+}
+
+// LifetimeWatcher renews a client's token in the background, ahead of its
+// expiration, so requests never have to wait for a token to be refreshed.
+type LifetimeWatcher struct {
+	t *transport
+
+	events chan LifetimeEvent
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// WatchTokenLifetime starts a LifetimeWatcher for the client's token. It
+// runs until ctx is canceled or Stop is called. Tokens are renewed with
+// renew-self while possible, and a new token is obtained from the client's
+// auth method otherwise.
+func (c *Client) WatchTokenLifetime(ctx context.Context) (*LifetimeWatcher, error) {
+	t, ok := c.hc.Transport.(*transport)
+	if !ok {
+		return nil, errors.New("client has no auth method to watch")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &LifetimeWatcher{
+		t:      t,
+		events: make(chan LifetimeEvent, 16),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		cancel()
+		return nil, errors.New("vault client is closed")
+	}
+
+	if t.watchers == nil {
+		t.watchers = make(map[*LifetimeWatcher]struct{})
+	}
+	t.watchers[w] = struct{}{}
+
+	go w.run(ctx)
+	return w, nil
+}
+
+// Events returns a channel of the refreshes performed by the watcher, it's
+// closed once the watcher stops. Events are dropped if the channel isn't
+// being read from.
+func (w *LifetimeWatcher) Events() <-chan LifetimeEvent {
+	return w.events
+}
+
+// Stop stops the watcher and waits for it to exit
+func (w *LifetimeWatcher) Stop() {
+	w.cancel()
+	<-w.done
+}
+
+// run refreshes the token whenever it's due until ctx is canceled
+func (w *LifetimeWatcher) run(ctx context.Context) {
+	defer close(w.done)
+	defer close(w.events)
+	defer w.t.removeWatcher(w)
+
+	var wait time.Duration
+	for {
+		if err := sleep(ctx, wait); err != nil {
+			return
+		}
+
+		ev, next := w.t.watch(ctx)
+		if ev != nil {
+			select {
+			case w.events <- *ev:
+			default:
+			}
+		}
+
+		wait = time.Until(next)
+	}
+}
+
+// removeWatcher removes a stopped LifetimeWatcher from the transport
+func (t *transport) removeWatcher(w *LifetimeWatcher) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.watchers, w)
+}
+
+// watch refreshes the token if it's due and returns the resulting event,
+// if any, and when watch should be called next.
+func (t *transport) watch(ctx context.Context) (*LifetimeEvent, time.Time) {
+	t.mu.Lock()
+	var call *refreshCall
+	if t.needsRefresh() {
+		call = t.startRefresh(ctx, false)
+	}
+	t.mu.Unlock()
+
+	var ev *LifetimeEvent
+	if call != nil {
+		if err := call.wait(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil, time.Now()
+			}
+			return &LifetimeEvent{Type: LifetimeEventFailed, Err: err}, time.Now().Add(lifetimeRetryInterval)
+		}
+
+		ev = &LifetimeEvent{Type: LifetimeEventReauthenticated}
+		if call.renewed {
+			ev.Type = LifetimeEventRenewed
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ev != nil {
+		ev.ExpiresAt = t.expiresAt
+	}
+
+	if t.expiresAt.IsZero() {
+		return ev, time.Now().Add(lifetimeIdleInterval)
+	}
+	return ev, t.refreshAt()
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to interact with arbitrary Vault paths
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+)
+
+// Secret is the generic response envelope returned by Vault, docs:
+// https://developer.hashicorp.com/vault/api-docs#api-operations
+type Secret struct {
+	// RequestID is the ID Vault assigned to the request
+	RequestID string `json:"request_id"`
+
+	// LeaseID is the ID of the lease for dynamic secrets, if any
+	LeaseID string `json:"lease_id"`
+
+	// LeaseDuration is how long the secret is valid for in seconds
+	LeaseDuration int `json:"lease_duration"`
+
+	// Renewable denotes if the lease can be renewed
+	Renewable bool `json:"renewable"`
+
+	// Data is the actual payload of the response
+	Data map[string]interface{} `json:"data"`
+
+	// Warnings are warnings that Vault returned alongside the response
+	Warnings []string `json:"warnings"`
+
+	// WrapInfo is set when the response was wrapped, see Client.Wrap
+	WrapInfo *WrapInfo `json:"wrap_info"`
+
+	// Auth is set when the response created a token, e.g. a login
+	Auth *SecretAuth `json:"auth"`
+}
+
+// SecretAuth is the authentication information returned by Vault when a
+// token is created, e.g. by a login.
+type SecretAuth struct {
+	// ClientToken is the actual token
+	ClientToken cfg.SecretData `json:"client_token"`
+
+	// Accessor is an accessor that can be used to lookup this token
+	Accessor string `json:"accessor"`
+
+	// Policies is a list of all policies attached to this token
+	Policies []string `json:"policies"`
+
+	// TokenPolicies is a list of policies attached directly to this token
+	TokenPolicies []string `json:"token_policies"`
+
+	// IdentityPolicies is a list of policies attached through the token's entity
+	IdentityPolicies []string `json:"identity_policies"`
+
+	// Metadata is the metadata attached to the token by the auth method
+	Metadata map[string]string `json:"metadata"`
+
+	// Orphan denotes if the token has no parent
+	Orphan bool `json:"orphan"`
+
+	// EntityID is the ID of the identity entity the token belongs to
+	EntityID string `json:"entity_id"`
+
+	// LeaseDuration is how long this token lives for in seconds
+	LeaseDuration int `json:"lease_duration"`
+
+	// Renewable denotes if the token can be renewed
+	Renewable bool `json:"renewable"`
+
+	// TokenType is the type of the token, either service or batch
+	TokenType string `json:"token_type"`
+}
+
+// Read reads the provided path. A path that doesn't exist returns an
+// error that satisfies IsNotFound.
+//
+//	// To read `secret/data/my/cool/secret` from a KV2 engine
+//	c.Read(ctx, "secret/data/my/cool/secret")
+func (c *Client) Read(ctx context.Context, logicalPath string) (*Secret, error) {
+	return c.logicalRequest(ctx, http.MethodGet, logicalPath, nil, nil)
+}
+
+// Write writes data to the provided path. If Vault returns no content,
+// e.g. for most configuration endpoints, a nil *Secret is returned.
+func (c *Client) Write(ctx context.Context, logicalPath string, data map[string]interface{}) (*Secret, error) {
+	return c.logicalRequest(ctx, http.MethodPost, logicalPath, nil, data)
+}
+
+// List lists the keys at the provided path, they're returned as
+// Data["keys"].
+func (c *Client) List(ctx context.Context, logicalPath string) (*Secret, error) {
+	return c.logicalRequest(ctx, "LIST", logicalPath, nil, nil)
+}
+
+// Delete deletes the provided path. If Vault returns no content a nil
+// *Secret is returned.
+func (c *Client) Delete(ctx context.Context, logicalPath string) (*Secret, error) {
+	return c.logicalRequest(ctx, http.MethodDelete, logicalPath, nil, nil)
+}
+
+// Patch applies data to the provided path as a JSON merge patch (RFC 7386),
+// this is only supported by some endpoints, e.g. KV2 data and metadata. If
+// Vault returns no content a nil *Secret is returned.
+func (c *Client) Patch(ctx context.Context, logicalPath string, data map[string]interface{}) (*Secret, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/merge-patch+json")
+	return c.logicalRequest(ctx, http.MethodPatch, logicalPath, header, data)
+}
+
+// logicalRequest sends a request to an arbitrary path and returns the
+// response as a *Secret, which is nil when Vault returned no content.
+func (c *Client) logicalRequest(ctx context.Context, method, logicalPath string, header http.Header,
+	data map[string]interface{}) (*Secret, error) {
+	var body interface{}
+	if data != nil {
+		body = data
+	}
+
+	var secret *Secret
+	if err := c.doRequestWithHeader(ctx, method, logicalPath, header, body, &secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClient_Logical(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+
+	if err := vc.CreateEngine(ctx, "kv", &CreateEngineOptions{Type: "kv"}); err != nil {
+		t.Errorf("Failed to create a kv engine: CreateEngine() = %v", err)
+		return
+	}
+
+	written, err := vc.Write(ctx, "kv/hello-world", map[string]interface{}{"hello": "world"})
+	if err != nil {
+		t.Errorf("Failed to write secret: Write() = %v", err)
+		return
+	}
+
+	if written != nil {
+		t.Errorf("Write(): expected no content, got %+v", written)
+	}
+
+	sec, err := vc.Read(ctx, "kv/hello-world")
+	if err != nil {
+		t.Errorf("Failed to read secret: Read() = %v", err)
+		return
+	}
+
+	if sec.RequestID == "" {
+		t.Error("Read(): expected the response to have a request id")
+	}
+
+	if diff := cmp.Diff(map[string]interface{}{"hello": "world"}, sec.Data); diff != "" {
+		t.Errorf("Read(): %s", diff)
+	}
+
+	list, err := vc.List(ctx, "kv")
+	if err != nil {
+		t.Errorf("Failed to list secrets: List() = %v", err)
+		return
+	}
+
+	if diff := cmp.Diff([]interface{}{"hello-world"}, list.Data["keys"]); diff != "" {
+		t.Errorf("List(): %s", diff)
+	}
+
+	if _, err := vc.Delete(ctx, "kv/hello-world"); err != nil {
+		t.Errorf("Failed to delete secret: Delete() = %v", err)
+		return
+	}
+
+	if _, err := vc.Read(ctx, "kv/hello-world"); !IsNotFound(err) {
+		t.Errorf("Read(): expected deleted secret to not be found, got %v", err)
+	}
+}
+
+func TestClient_Patch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.Header.Get("Content-Type") != "application/merge-patch+json" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte(`{"errors":["unexpected request"]}`)) //nolint:errcheck // Why: test server
+			return
+		}
+		w.Write([]byte(`{"request_id":"abc","data":{"version":2}}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	c := New(WithAddress(srv.URL))
+	sec, err := c.Patch(context.Background(), "secret/data/foo", map[string]interface{}{
+		"data": map[string]interface{}{"hello": "world"},
+	})
+	if err != nil {
+		t.Errorf("Failed to patch secret: Patch() = %v", err)
+		return
+	}
+
+	if sec.RequestID != "abc" || sec.Data["version"] != float64(2) {
+		t.Errorf("Patch(): unexpected response %+v", sec)
+	}
+}
+
+func TestClient_Logical_AuthAndWrapping(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+
+	tokenResp, err := vc.Write(ctx, "auth/token/create", map[string]interface{}{
+		"policies": []string{"default"},
+		"ttl":      "1h",
+	})
+	if err != nil {
+		t.Errorf("Failed to create token: Write() = %v", err)
+		return
+	}
+
+	if tokenResp.Auth == nil || tokenResp.Auth.ClientToken == "" || tokenResp.Auth.LeaseDuration != 3600 {
+		t.Errorf("Write(): expected auth with a token and lease duration, got %+v", tokenResp.Auth)
+	}
+
+	var wrapped *Secret
+	wrapInfo, err := vc.Wrap(time.Minute, func(wc *Client) error {
+		var err error
+		wrapped, err = wc.Write(ctx, "auth/token/create", map[string]interface{}{"policies": []string{"default"}})
+		return err
+	})
+	if err != nil {
+		t.Errorf("Failed to wrap token creation: Wrap() = %v", err)
+		return
+	}
+
+	if wrapped.WrapInfo == nil || wrapped.WrapInfo.Token != wrapInfo.Token {
+		t.Errorf("Write(): expected wrapped response to include the wrap info, got %+v", wrapped)
+	}
+}
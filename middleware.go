@@ -0,0 +1,146 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements middleware that wraps requests made to Vault
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// redacted replaces the values of secret-bearing fields in request
+// bodies passed to middleware.
+const redacted = "[REDACTED]"
+
+// RequestInfo is a request to Vault as seen by a Middleware
+type RequestInfo struct {
+	// Method is the HTTP method of the request, e.g. GET or LIST
+	Method string
+
+	// Endpoint is the logical path (without the /v1/ prefix) of the request
+	Endpoint string
+
+	// Header are additional headers sent with the request, middleware may
+	// add to it (e.g. per-tenant headers). The Authorization header is added
+	// later by the auth transport and is never visible here.
+	Header http.Header
+
+	// Body is the JSON decoded request body, or nil if there is none.
+	// Every field that isn't known to be free of secrets, e.g. token_ttl,
+	// is redacted unless WithUnredactedBodies is used. Changing it has no
+	// effect on the request.
+	Body interface{}
+
+	// StatusCode is the HTTP status code returned by Vault, it's set
+	// once the next RequestHandler returns and is 0 if no response was
+	// received.
+	StatusCode int
+
+	// Latency is how long the request took, including retries, it's set
+	// once the next RequestHandler returns.
+	Latency time.Duration
+}
+
+// RequestHandler sends a request to Vault and decodes the response,
+// returning the decoded error (see ResponseError) if there is one.
+type RequestHandler func(ctx context.Context, req *RequestInfo) error
+
+// Middleware wraps a RequestHandler, e.g. to add audit logging, metrics
+// or headers to every request made by a Client. Middleware may also return
+// an error without calling next to fail a request.
+type Middleware func(next RequestHandler) RequestHandler
+
+// wrapMiddleware wraps h with the client's middleware, the first middleware
+// is the outermost.
+func (c *Client) wrapMiddleware(h RequestHandler) RequestHandler {
+	for i := len(c.opts.Middleware) - 1; i >= 0; i-- {
+		h = c.opts.Middleware[i](h)
+	}
+	return h
+}
+
+// middlewareBody returns the body of a request as passed to middleware
+func (c *Client) middlewareBody(b []byte) interface{} {
+	if len(c.opts.Middleware) == 0 || b == nil {
+		return nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil
+	}
+
+	if c.opts.UnredactedBodies {
+		return body
+	}
+	return redactBody(body)
+}
+
+// redactBody replaces the values of all fields in body that aren't known to
+// be free of secrets, see publicFields. The values of public fields are
+// redacted recursively, e.g. the plaintext of transit batch_input.
+func redactBody(body interface{}) interface{} {
+	switch v := body.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			if !isPublicField(k) {
+				v[k] = redacted
+				continue
+			}
+			v[k] = redactBody(fv)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactBody(v[i])
+		}
+	}
+	return body
+}
+
+// publicFields are the names of request body fields that don't contain
+// secrets, matched case-insensitively. Every other field is redacted, since
+// requests made through e.g. Write can contain arbitrary secrets.
+var publicFields = map[string]bool{
+	// engines, auth methods and policies
+	"type": true, "description": true, "config": true, "options": true, "version": true, "policy": true, "rules": true,
+	"default_lease_ttl": true, "max_lease_ttl": true, "max_versions": true, "cas": true, "cas_required": true,
+
+	// roles
+	"name": true, "role": true, "role_name": true, "role_type": true, "default_role": true, "path_suffix": true,
+	"policies": true, "allowed_policies": true, "disallowed_policies": true, "allowed_policies_glob": true,
+	"disallowed_policies_glob": true, "allowed_entity_aliases": true, "bind_secret_id": true, "local_secret_ids": true,
+	"secret_id_ttl": true, "secret_id_num_uses": true, "secret_id_bound_cidrs": true, "cidr_list": true,
+	"user_claim": true, "bound_audiences": true, "bound_subject": true, "bound_claims": true, "bound_claims_type": true,
+	"claim_mappings": true, "allowed_redirect_uris": true, "oidc_scopes": true, "redirect_uri": true,
+	"bound_service_account_names": true, "bound_service_account_namespaces": true, "audience": true,
+	"allowed_common_names": true, "allowed_dns_sans": true, "allowed_email_sans": true, "allowed_uri_sans": true,
+	"allowed_organizational_units": true, "certificate": true,
+
+	// auth method configuration
+	"kubernetes_host": true, "kubernetes_ca_cert": true, "issuer": true, "disable_iss_validation": true,
+	"disable_local_ca_jwt": true, "pem_keys": true, "oidc_discovery_url": true, "oidc_discovery_ca_pem": true,
+	"oidc_client_id": true, "jwks_url": true, "jwks_ca_pem": true, "jwt_validation_pubkeys": true,
+	"jwt_supported_algs": true, "bound_issuer": true, "iam_http_request_method": true,
+
+	// tokens, the token itself is secret but not its properties
+	"token_ttl": true, "token_max_ttl": true, "token_explicit_max_ttl": true, "token_policies": true,
+	"token_bound_cidrs": true, "token_no_default_policy": true, "token_num_uses": true, "token_period": true,
+	"token_type": true, "ttl": true, "explicit_max_ttl": true, "period": true, "num_uses": true,
+	"no_default_policy": true, "renewable": true, "display_name": true, "entity_alias": true, "increment": true,
+	"accessor": true, "secret_id_accessor": true,
+
+	// sys
+	"secret_shares": true, "secret_threshold": true, "recovery_shares": true, "recovery_threshold": true,
+
+	// transit, the values of a batch are redacted individually
+	"batch_input": true, "key_version": true,
+}
+
+// isPublicField returns true if a request body field with the given name
+// is known not to contain a secret, e.g. token_ttl or policies.
+func isPublicField(name string) bool {
+	return publicFields[strings.ToLower(name)]
+}
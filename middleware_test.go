@@ -0,0 +1,170 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestClient_Middleware(t *testing.T) {
+	var gotTenant string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = r.Header.Get("X-Tenant")
+
+		if r.URL.Path == "/v1/auth/approle/login" {
+			w.Write([]byte(`{"auth":{"client_token":"token","lease_duration":3600}}`)) //nolint:errcheck // Why: test server
+			return
+		}
+
+		// the token is valid, the request is denied by policy
+		if r.URL.Path == "/v1/auth/token/lookup-self" {
+			w.Write([]byte(`{"data":{"id":"token"}}`)) //nolint:errcheck // Why: test server
+			return
+		}
+
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	var calls []string
+	var seen []RequestInfo
+	record := func(name string) Middleware {
+		return func(next RequestHandler) RequestHandler {
+			return func(ctx context.Context, req *RequestInfo) error {
+				calls = append(calls, name+":before")
+				req.Header.Set("X-Tenant", "acme")
+
+				err := next(ctx, req)
+				calls = append(calls, name+":after")
+
+				if name == "outer" {
+					if !IsPermissionDenied(err) && req.Endpoint == "transit/encrypt/key" {
+						t.Errorf("expected middleware to see the decoded error, got %v", err)
+					}
+					if req.Latency <= 0 {
+						t.Error("expected middleware to see the request latency")
+					}
+					seen = append(seen, *req)
+				}
+				return err
+			}
+		}
+	}
+
+	c := New(WithAddress(srv.URL), WithApproleAuth("role-id", "secret-id"),
+		WithMiddleware(record("outer"), record("inner")))
+	if _, err := c.TransitEncrypt(context.Background(), "key", []byte("hello")); !IsPermissionDenied(err) {
+		t.Errorf("TransitEncrypt(): expected permission denied, got %v", err)
+	}
+
+	if gotTenant != "acme" {
+		t.Errorf("expected header set by middleware to be sent, got %q", gotTenant)
+	}
+
+	// the approle login, made by the auth method, and the lookup of the
+	// denied token are nested in the request
+	wantCalls := []string{
+		"outer:before", "inner:before",
+		"outer:before", "inner:before", "inner:after", "outer:after",
+		"outer:before", "inner:before", "inner:after", "outer:after",
+		"inner:after", "outer:after",
+	}
+	if diff := cmp.Diff(wantCalls, calls); diff != "" {
+		t.Errorf("middleware order: %s", diff)
+	}
+
+	if len(seen) != 3 {
+		t.Errorf("expected 3 requests to be seen by middleware, got %d", len(seen))
+		return
+	}
+
+	login, lookup, encrypt := seen[0], seen[1], seen[2]
+	if login.Method != http.MethodPost || login.Endpoint != "auth/approle/login" || login.StatusCode != http.StatusOK {
+		t.Errorf("unexpected login request: %+v", login)
+	}
+
+	wantBody := map[string]interface{}{"role_id": redacted, "secret_id": redacted}
+	if diff := cmp.Diff(wantBody, login.Body); diff != "" {
+		t.Errorf("expected login body to be redacted: %s", diff)
+	}
+
+	if lookup.Endpoint != "auth/token/lookup-self" || lookup.StatusCode != http.StatusOK {
+		t.Errorf("unexpected lookup request: %+v", lookup)
+	}
+
+	if encrypt.Endpoint != "transit/encrypt/key" || encrypt.StatusCode != http.StatusForbidden {
+		t.Errorf("unexpected encrypt request: %+v", encrypt)
+	}
+
+	if diff := cmp.Diff(map[string]interface{}{"plaintext": redacted}, encrypt.Body); diff != "" {
+		t.Errorf("expected encrypt body to be redacted: %s", diff)
+	}
+}
+
+func TestClient_Middleware_Unredacted(t *testing.T) {
+	errChaos := errors.New("chaos")
+
+	var body interface{}
+	c := New(WithAddress("http://127.0.0.1:0"), WithUnredactedBodies(), WithMiddleware(func(next RequestHandler) RequestHandler {
+		return func(ctx context.Context, req *RequestInfo) error {
+			body = req.Body
+			return errChaos
+		}
+	}))
+
+	err := c.CreateKV2Secret(context.Background(), "deploy", "hello", map[string]interface{}{"hello": "world"})
+	if !errors.Is(err, errChaos) {
+		t.Errorf("CreateKV2Secret(): expected error returned by middleware, got %v", err)
+	}
+
+	want := map[string]interface{}{"data": map[string]interface{}{"hello": "world"}}
+	if diff := cmp.Diff(want, body); diff != "" {
+		t.Errorf("expected unredacted body: %s", diff)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	body := map[string]interface{}{
+		"token":              "s.token",
+		"secret_id":          "secret",
+		"Password":           "hunter2",
+		"db_password":        "hunter2",
+		"api_token":          "token",
+		"ssh_private_key":    "-----BEGIN",
+		"anything":           "else",
+		"token_ttl":          "1h",
+		"token_policies":     []interface{}{"default"},
+		"token_type":         "service",
+		"token_num_uses":     float64(1),
+		"secret_id_num_uses": float64(2),
+		"config":             map[string]interface{}{"max_lease_ttl": "1h", "client_secret": "secret"},
+		"batch_input":        []interface{}{map[string]interface{}{"plaintext": "aGk=", "context": "ctx"}},
+	}
+
+	want := map[string]interface{}{
+		"token":              redacted,
+		"secret_id":          redacted,
+		"Password":           redacted,
+		"db_password":        redacted,
+		"api_token":          redacted,
+		"ssh_private_key":    redacted,
+		"anything":           redacted,
+		"token_ttl":          "1h",
+		"token_policies":     []interface{}{"default"},
+		"token_type":         "service",
+		"token_num_uses":     float64(1),
+		"secret_id_num_uses": float64(2),
+		"config":             map[string]interface{}{"max_lease_ttl": "1h", "client_secret": redacted},
+		"batch_input":        []interface{}{map[string]interface{}{"plaintext": redacted, "context": redacted}},
+	}
+
+	if diff := cmp.Diff(want, redactBody(body)); diff != "" {
+		t.Errorf("redactBody(): %s", diff)
+	}
+}
@@ -4,10 +4,15 @@
 package vault_client //nolint:revive // Why: We're using - in the name
 
 import (
+	"net/http"
 	"os" // Options is the options used by the New() client function
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/getoutreach/gobox/pkg/cfg"
 	"github.com/imdario/mergo"
+	"github.com/pkg/errors"
 )
 
 // Options are options associated with a Vault client
@@ -17,27 +22,174 @@ type Options struct {
 	// authentication
 	am AuthMethod
 
+	// envErr is an invalid configuration read by WithEnv, every request
+	// fails with it
+	envErr error
+
+	// tr is the underlying http.RoundTripper used to talk to Vault, it's
+	// created by New() and shared with clients created by auth methods
+	// so that connections (and TLS settings) are reused.
+	tr http.RoundTripper
+
 	// Host is the host of the Vault instance
 	Host string
+
+	// Namespace is the Vault Enterprise namespace that requests are
+	// made in, it's sent as the X-Vault-Namespace header.
+	Namespace string
+
+	// TLS configures how the connection to Vault is secured, if nil
+	// the system defaults are used.
+	TLS *TLSConfig
+
+	// RetryPolicy controls how failed requests are retried, if nil
+	// requests are not retried.
+	RetryPolicy *RetryPolicy
+
+	// Middleware wraps every request made by the client, the first
+	// middleware is the outermost. See WithMiddleware.
+	Middleware []Middleware
+
+	// UnredactedBodies disables redaction of secret-bearing fields in
+	// request bodies passed to Middleware.
+	UnredactedBodies bool
+
+	// RevokeStaticTokenOnClose makes Client.Close revoke tokens provided
+	// by the caller, e.g. through WithTokenAuth, too.
+	RevokeStaticTokenOnClose bool
+
+	// WrapTTL, if at least 1s, wraps every response with this TTL by
+	// default. See WithWrapTTL.
+	WrapTTL time.Duration
 }
 
 // Opts is an functional option for use with New()
 type Opts func(*Options)
 
+// EnvAuthSource is a source of credentials read by WithEnv
+type EnvAuthSource string
+
+// Contains the EnvAuthSources supported by WithEnv
+const (
+	// EnvAuthToken uses the token in VAULT_TOKEN
+	EnvAuthToken EnvAuthSource = "token"
+
+	// EnvAuthApprole uses the approle credentials in VAULT_ROLE_ID and
+	// VAULT_SECRET_ID, or the secret_id in the file VAULT_SECRET_ID_FILE
+	EnvAuthApprole EnvAuthSource = "approle"
+
+	// EnvAuthKubernetes uses the service-account token to login as the
+	// role in VAULT_KUBERNETES_ROLE, VAULT_KUBERNETES_MOUNT and
+	// VAULT_KUBERNETES_TOKEN_PATH optionally override the mount and the
+	// path of the service-account token.
+	EnvAuthKubernetes EnvAuthSource = "kubernetes"
+
+	// EnvAuthTokenFile uses the token in ~/.vault-token, it's always
+	// considered to be configured.
+	EnvAuthTokenFile EnvAuthSource = "token_file"
+
+	// EnvAuthTokenHelper uses the token stored by the token helper the
+	// vault CLI is configured with, or ~/.vault-token if there is none. It's
+	// always considered to be configured.
+	EnvAuthTokenHelper EnvAuthSource = "token_helper"
+)
+
+// DefaultEnvAuthPrecedence is the order WithEnv tries the configured
+// EnvAuthSources in: VAULT_TOKEN, then approle and then kubernetes.
+var DefaultEnvAuthPrecedence = []EnvAuthSource{EnvAuthToken, EnvAuthApprole, EnvAuthKubernetes}
+
 // WithEnv reads configuration from environment variables
-// and returns an Options based off of the values
+// and returns an Options based off of the values. Credentials
+// are tried in the order of DefaultEnvAuthPrecedence, see
+// WithEnvAuthPrecedence.
 func WithEnv(opts *Options) {
-	if roleID, ok := os.LookupEnv("VAULT_ROLE_ID"); ok {
-		WithApproleAuth(cfg.SecretData(roleID), cfg.SecretData(os.Getenv("VAULT_SECRET_ID")))(opts)
+	withEnv(opts, DefaultEnvAuthPrecedence)
+}
+
+// WithEnvAuthPrecedence reads configuration from environment variables,
+// like WithEnv, but tries the credentials in the order of precedence. If
+// more than one source is configured they're combined using a
+// ChainAuthMethod, falling back to the next source if one fails.
+func WithEnvAuthPrecedence(precedence ...EnvAuthSource) Opts {
+	return func(opts *Options) {
+		withEnv(opts, precedence)
 	}
+}
+
+// withEnv implements WithEnv using the provided auth precedence
+func withEnv(opts *Options, precedence []EnvAuthSource) {
+	withEnvAuth(opts, precedence)
 
 	if host, ok := os.LookupEnv("VAULT_ADDR"); ok {
 		WithAddress(host)(opts)
 	}
 
-	if token, ok := os.LookupEnv("VAULT_TOKEN"); ok {
-		WithTokenAuth(cfg.SecretData(token))(opts)
+	if namespace, ok := os.LookupEnv("VAULT_NAMESPACE"); ok {
+		WithNamespace(namespace)(opts)
+	}
+
+	withTLSEnv(opts)
+
+	// VAULT_MAX_RETRIES follows the Vault CLI, it's the number of retries
+	// and not the number of attempts.
+	if retries, err := strconv.Atoi(os.Getenv("VAULT_MAX_RETRIES")); err == nil {
+		policy := DefaultRetryPolicy()
+		policy.MaxAttempts = retries + 1
+		WithRetryPolicy(policy)(opts)
+	}
+}
+
+// withEnvAuth sets up authentication using the credentials, in the order
+// of precedence, that are configured in the environment
+func withEnvAuth(opts *Options, precedence []EnvAuthSource) {
+	var methods []AuthMethod
+	for _, src := range precedence {
+		if am := src.authMethod(); am != nil {
+			methods = append(methods, am)
+		}
+	}
+
+	switch len(methods) {
+	case 0:
+	case 1:
+		opts.am = methods[0]
+	default:
+		opts.am = NewChainAuthMethod(methods...)
+	}
+}
+
+// authMethod returns the AuthMethod for the source, or nil if it's not
+// configured in the environment
+func (src EnvAuthSource) authMethod() AuthMethod {
+	switch src {
+	case EnvAuthToken:
+		if token, ok := os.LookupEnv("VAULT_TOKEN"); ok {
+			return NewTokenAuthMethod(cfg.SecretData(token))
+		}
+	case EnvAuthApprole:
+		roleID, ok := os.LookupEnv("VAULT_ROLE_ID")
+		if !ok {
+			return nil
+		}
+
+		if file, ok := os.LookupEnv("VAULT_SECRET_ID_FILE"); ok {
+			return NewApproleAuthMethodWithProvider(cfg.SecretData(roleID), NewSecretIDFile(file, false))
+		}
+		return NewApproleAuthMethod(cfg.SecretData(roleID), cfg.SecretData(os.Getenv("VAULT_SECRET_ID")))
+	case EnvAuthKubernetes:
+		if role, ok := os.LookupEnv("VAULT_KUBERNETES_ROLE"); ok {
+			return NewKubernetesAuthMethod(&KubernetesAuthOptions{
+				Role:      role,
+				Mount:     os.Getenv("VAULT_KUBERNETES_MOUNT"),
+				TokenPath: os.Getenv("VAULT_KUBERNETES_TOKEN_PATH"),
+			})
+		}
+	case EnvAuthTokenFile:
+		return NewTokenFileAuthMethod(nil)
+	case EnvAuthTokenHelper:
+		return NewTokenHelperAuthMethod(nil)
 	}
+	return nil
 }
 
 // WithApproleAuth sets up approle authentication on a Client
@@ -47,6 +199,58 @@ func WithApproleAuth(roleID, secretID cfg.SecretData) Opts {
 	}
 }
 
+// WithKubernetesAuth sets up kubernetes service-account authentication
+// on a Client
+func WithKubernetesAuth(kopts *KubernetesAuthOptions) Opts {
+	return func(opts *Options) {
+		opts.am = NewKubernetesAuthMethod(kopts)
+	}
+}
+
+// WithJWTAuth sets up JWT authentication on a Client
+func WithJWTAuth(jopts *JWTAuthOptions) Opts {
+	return func(opts *Options) {
+		opts.am = NewJWTAuthMethod(jopts)
+	}
+}
+
+// WithAWSIAMAuth sets up AWS IAM authentication on a Client
+func WithAWSIAMAuth(aopts *AWSIAMAuthOptions) Opts {
+	return func(opts *Options) {
+		opts.am = NewAWSIAMAuthMethod(aopts)
+	}
+}
+
+// WithCertAuth sets up TLS certificate authentication on a Client, the
+// client certificate is configured with WithClientCert.
+func WithCertAuth(copts *CertAuthOptions) Opts {
+	return func(opts *Options) {
+		opts.am = NewCertAuthMethod(copts)
+	}
+}
+
+// WithUserpassAuth sets up userpass authentication on a Client
+func WithUserpassAuth(uopts *UserpassAuthOptions) Opts {
+	return func(opts *Options) {
+		opts.am = NewUserpassAuthMethod(uopts)
+	}
+}
+
+// WithLDAPAuth sets up LDAP authentication on a Client
+func WithLDAPAuth(lopts *LDAPAuthOptions) Opts {
+	return func(opts *Options) {
+		opts.am = NewLDAPAuthMethod(lopts)
+	}
+}
+
+// WithApproleSecretIDProvider sets up approle authentication on a Client
+// using a SecretIDProvider, e.g. a SecretIDFile
+func WithApproleSecretIDProvider(roleID cfg.SecretData, secretID SecretIDProvider) Opts {
+	return func(opts *Options) {
+		opts.am = NewApproleAuthMethodWithProvider(roleID, secretID)
+	}
+}
+
 // WithTokenAuth sets up token authentication on a Client
 func WithTokenAuth(token cfg.SecretData) Opts {
 	return func(opts *Options) {
@@ -61,6 +265,14 @@ func WithTokenFileAuth(path *string) Opts {
 	}
 }
 
+// WithTokenHelperAuth sets up auth using the vault CLI's token helper on a
+// Client, configPath is the vault CLI config file and defaults to ~/.vault
+func WithTokenHelperAuth(configPath *string) Opts {
+	return func(opts *Options) {
+		opts.am = NewTokenHelperAuthMethod(configPath)
+	}
+}
+
 // WithAddress sets the host to use when talking to Vault on a Client
 func WithAddress(hostname string) Opts {
 	return func(opts *Options) {
@@ -68,9 +280,179 @@ func WithAddress(hostname string) Opts {
 	}
 }
 
+// WithNamespace sets the Vault Enterprise namespace to use on a Client
+func WithNamespace(namespace string) Opts {
+	return func(opts *Options) {
+		opts.Namespace = strings.Trim(namespace, "/")
+	}
+}
+
+// withTLSEnv reads the TLS configuration from the same environment
+// variables the Vault CLI uses.
+func withTLSEnv(opts *Options) {
+	if caCert, ok := os.LookupEnv("VAULT_CACERT"); ok {
+		WithCACert(caCert)(opts)
+	}
+
+	if caPath, ok := os.LookupEnv("VAULT_CAPATH"); ok {
+		WithCAPath(caPath)(opts)
+	}
+
+	clientCert, certOK := os.LookupEnv("VAULT_CLIENT_CERT")
+	clientKey, keyOK := os.LookupEnv("VAULT_CLIENT_KEY")
+	if certOK || keyOK {
+		WithClientCert(clientCert, clientKey)(opts)
+	}
+
+	if serverName, ok := os.LookupEnv("VAULT_TLS_SERVER_NAME"); ok {
+		WithTLSServerName(serverName)(opts)
+	}
+
+	// match the Vault CLI, which ignores an empty value and rejects a
+	// value it can't parse, in which case verification stays on
+	if skipVerify := os.Getenv("VAULT_SKIP_VERIFY"); skipVerify != "" {
+		insecure, err := strconv.ParseBool(skipVerify)
+		if err != nil {
+			opts.envErr = errors.Errorf("invalid VAULT_SKIP_VERIFY %q, expected a boolean", skipVerify)
+			return
+		}
+		WithInsecureSkipVerify(insecure)(opts)
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used on a Client
+func WithTLSConfig(conf *TLSConfig) Opts {
+	return func(opts *Options) {
+		opts.TLS = conf
+	}
+}
+
+// withTLS modifies a copy of the Client's TLS configuration, so that
+// options copied from another client aren't changed.
+func withTLS(fn func(*TLSConfig)) Opts {
+	return func(opts *Options) {
+		conf := opts.TLS.clone()
+		fn(conf)
+		opts.TLS = conf
+	}
+}
+
+// WithCACert sets the PEM encoded CA certificate file used to verify Vault
+func WithCACert(file string) Opts {
+	return withTLS(func(conf *TLSConfig) {
+		conf.CACert = file
+	})
+}
+
+// WithCAPath sets a directory of PEM encoded CA certificates used to verify Vault
+func WithCAPath(dir string) Opts {
+	return withTLS(func(conf *TLSConfig) {
+		conf.CAPath = dir
+	})
+}
+
+// WithCACertBytes sets the PEM encoded CA certificates used to verify Vault
+func WithCACertBytes(pem []byte) Opts {
+	return withTLS(func(conf *TLSConfig) {
+		conf.CACertBytes = pem
+	})
+}
+
+// WithClientCert sets the PEM encoded client certificate and key files
+// presented to Vault on a Client
+func WithClientCert(certFile, keyFile string) Opts {
+	return withTLS(func(conf *TLSConfig) {
+		conf.ClientCert = certFile
+		conf.ClientKey = keyFile
+	})
+}
+
+// WithTLSServerName sets the server name used to verify Vault's certificate
+func WithTLSServerName(serverName string) Opts {
+	return withTLS(func(conf *TLSConfig) {
+		conf.ServerName = serverName
+	})
+}
+
+// WithInsecureSkipVerify disables verification of Vault's certificate
+// when insecure is true. Only use this for local development.
+func WithInsecureSkipVerify(insecure bool) Opts {
+	return withTLS(func(conf *TLSConfig) {
+		conf.Insecure = insecure
+	})
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests on a Client,
+// see DefaultRetryPolicy for a reasonable starting point.
+func WithRetryPolicy(policy *RetryPolicy) Opts {
+	return func(opts *Options) {
+		opts.RetryPolicy = policy
+	}
+}
+
+// WithMiddleware adds middleware to a Client, it's called for every
+// request made by the client (including logins made by its auth method)
+// before authentication is added. Middleware is called in the order it's
+// provided, with the first being the outermost.
+func WithMiddleware(mw ...Middleware) Opts {
+	return func(opts *Options) {
+		opts.Middleware = append(append([]Middleware{}, opts.Middleware...), mw...)
+	}
+}
+
+// WithUnredactedBodies passes request bodies to middleware as is, without
+// redacting secret-bearing fields. Only use this when the middleware can
+// be trusted with secrets.
+func WithUnredactedBodies() Opts {
+	return func(opts *Options) {
+		opts.UnredactedBodies = true
+	}
+}
+
+// WithRevokeStaticTokenOnClose makes Client.Close revoke the client's token
+// even when it was provided by the caller, rather than obtained by logging
+// in, e.g. with WithTokenAuth, WithTokenFileAuth or WithTokenHelperAuth.
+func WithRevokeStaticTokenOnClose() Opts {
+	return func(opts *Options) {
+		opts.RevokeStaticTokenOnClose = true
+	}
+}
+
+// WithWrapTTL wraps every response of the client with the provided TTL, as
+// if the request was made with the X-Vault-Wrap-TTL header, e.g. so every
+// secret read is only handed out through a wrapping token. The wrap_info is
+// available in Secret.WrapInfo, methods returning a typed response return a
+// *WrappedResponseError with the wrap_info instead, see WrapInfoFromError.
+// Client.Wrap
+// takes precedence for the request it wraps, and the sys/wrapping endpoints
+// are never wrapped. A TTL shorter than 1s disables wrapping.
+func WithWrapTTL(ttl time.Duration) Opts {
+	return func(opts *Options) {
+		opts.WrapTTL = ttl
+	}
+}
+
 // WithOptions combines a provided options with the client's
 func WithOptions(oldO *Options) Opts {
 	return func(newO *Options) {
 		mergo.MergeWithOverwrite(newO, oldO) //nolint:errcheck // Why: sig doesn't allow
 	}
 }
+
+// withInheritedOptions copies all of the provided options except for the
+// auth method. It's used by auth methods that need their own client to
+// talk to the same Vault instance the parent client does.
+func withInheritedOptions(oldO *Options) Opts {
+	return func(newO *Options) {
+		if oldO == nil {
+			return
+		}
+
+		*newO = *oldO
+		newO.am = nil
+
+		// the clients of auth methods log in, renew and unwrap, which
+		// mustn't be wrapped
+		newO.WrapTTL = 0
+	}
+}
@@ -2,7 +2,7 @@
 package vault_client //nolint:revive // Why: We're using - in the name
 
 import (
-	"os"
+	"fmt"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -12,16 +12,81 @@ import (
 func TestWithEnv(t *testing.T) {
 	vaultAddr := "http://127.0.0.1:1011"
 
-	os.Setenv("VAULT_ADDR", vaultAddr)
+	t.Setenv("VAULT_ADDR", vaultAddr)
+	t.Setenv("VAULT_NAMESPACE", "team/")
 
 	opts := &Options{}
 	WithEnv(opts)
 
 	expected := &Options{
-		Host: vaultAddr,
+		Host:      vaultAddr,
+		Namespace: "team",
 	}
 
 	if diff := cmp.Diff(opts, expected, cmpopts.IgnoreUnexported(Options{})); diff != "" {
 		t.Errorf("cmp.Diff = %s", diff)
 	}
 }
+
+func TestWithEnv_KubernetesAuth(t *testing.T) {
+	t.Setenv("VAULT_KUBERNETES_ROLE", "app")
+	t.Setenv("VAULT_KUBERNETES_MOUNT", "k8s")
+
+	opts := &Options{}
+	WithEnv(opts)
+
+	am, ok := opts.am.(*KubernetesAuthMethod)
+	if !ok {
+		t.Errorf("expected a KubernetesAuthMethod, got %T", opts.am)
+		return
+	}
+
+	if am.role != "app" || am.mount != "k8s" || am.tokenPath != DefaultKubernetesTokenPath {
+		t.Errorf("unexpected KubernetesAuthMethod: %+v", am)
+	}
+}
+
+func TestWithEnv_AuthPrecedence(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "token")
+	t.Setenv("VAULT_ROLE_ID", "role-id")
+	t.Setenv("VAULT_SECRET_ID", "secret-id")
+
+	types := func(opts *Options) []string {
+		chain, ok := opts.am.(*ChainAuthMethod)
+		if !ok {
+			return []string{fmt.Sprintf("%T", opts.am)}
+		}
+
+		var types []string
+		for _, am := range chain.methods {
+			types = append(types, fmt.Sprintf("%T", am))
+		}
+		return types
+	}
+
+	opts := &Options{}
+	WithEnv(opts)
+	if diff := cmp.Diff([]string{"*vault_client.TokenAuthMethod", "*vault_client.ApproleAuthMethod"}, types(opts)); diff != "" {
+		t.Errorf("WithEnv(): %s", diff)
+	}
+
+	opts = &Options{}
+	WithEnvAuthPrecedence(EnvAuthKubernetes, EnvAuthApprole, EnvAuthToken, EnvAuthTokenFile)(opts)
+	want := []string{"*vault_client.ApproleAuthMethod", "*vault_client.TokenAuthMethod", "*vault_client.TokenFileAuthMethod"}
+	if diff := cmp.Diff(want, types(opts)); diff != "" {
+		t.Errorf("WithEnvAuthPrecedence(): %s", diff)
+	}
+
+	opts = &Options{}
+	WithEnvAuthPrecedence(EnvAuthTokenHelper, EnvAuthApprole)(opts)
+	want = []string{"*vault_client.TokenHelperAuthMethod", "*vault_client.ApproleAuthMethod"}
+	if diff := cmp.Diff(want, types(opts)); diff != "" {
+		t.Errorf("WithEnvAuthPrecedence(): %s", diff)
+	}
+
+	opts = &Options{}
+	WithEnvAuthPrecedence(EnvAuthApprole)(opts)
+	if diff := cmp.Diff([]string{"*vault_client.ApproleAuthMethod"}, types(opts)); diff != "" {
+		t.Errorf("WithEnvAuthPrecedence(): %s", diff)
+	}
+}
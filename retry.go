@@ -0,0 +1,178 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Implements retrying of requests made to Vault
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy controls how requests that fail with a retryable error
+// are retried by a Client.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts made for a request,
+	// including the first one. Values <= 1 disable retries.
+	MaxAttempts int
+
+	// MinBackoff is the backoff used before the first retry, it is doubled
+	// for every following retry.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum backoff used between two attempts.
+	MaxBackoff time.Duration
+
+	// RetryableStatusCodes are the HTTP status codes that should be retried.
+	// They're not retried for sys/health, whose status code reports the
+	// state of Vault.
+	RetryableStatusCodes []int
+
+	// RetryableMethods are the HTTP methods that are retried. By default only
+	// methods that are safe to replay in Vault are retried, note that Vault
+	// treats PUT the same as POST so it is not included.
+	RetryableMethods []string
+
+	// IgnoreRetryAfter disables honoring the Retry-After header returned
+	// by Vault (or a load balancer in front of it). The wait requested by
+	// Retry-After is capped at MaxBackoff.
+	IgnoreRetryAfter bool
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used by WithEnv when
+// VAULT_MAX_RETRIES is set. It retries idempotent requests twice.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  500 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+		RetryableStatusCodes: []int{
+			http.StatusPreconditionFailed, // returned by Vault when a standby isn't caught up yet
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryableMethods: []string{http.MethodGet, http.MethodHead, "LIST"},
+	}
+}
+
+// retryable returns true if a request with the given method may be retried
+func (p *RetryPolicy) retryable(method string) bool {
+	if p == nil || p.MaxAttempts <= 1 {
+		return false
+	}
+
+	for _, m := range p.RetryableMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// statusEndpoints are the endpoints whose status code reports the state of
+// Vault, e.g. 429 for a standby or 503 when sealed, rather than a failure
+// that should be retried.
+var statusEndpoints = map[string]bool{
+	"sys/health": true,
+}
+
+// shouldRetry determines if the result of the provided attempt (starting at 1)
+// of a request for endpoint should be retried, and if so how long to wait
+// before doing so.
+func (p *RetryPolicy) shouldRetry(ctx context.Context, method, endpoint string, attempt int,
+	r *http.Response, err error) (time.Duration, bool) {
+	if !p.retryable(method) || attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	if err != nil {
+		return p.backoff(attempt), true
+	}
+
+	if statusEndpoints[strings.Trim(endpoint, "/")] {
+		return 0, false
+	}
+
+	for _, code := range p.RetryableStatusCodes {
+		if r.StatusCode != code {
+			continue
+		}
+
+		if wait, ok := retryAfter(r); ok && !p.IgnoreRetryAfter {
+			// don't let Vault, or a load balancer, stall the request
+			// for longer than the policy allows
+			if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+				wait = p.MaxBackoff
+			}
+			return wait, true
+		}
+		return p.backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns the exponential backoff, with jitter, to wait after the
+// provided attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.MinBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || wait < p.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+
+	if wait <= 1 {
+		return wait
+	}
+
+	// use "equal jitter" to spread out retries from multiple clients while
+	// still guaranteeing some backoff
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)) //nolint:gosec // Why: jitter doesn't need crypto/rand
+}
+
+// retryAfter parses the Retry-After header of a response, if it's present
+func retryAfter(r *http.Response) (time.Duration, bool) {
+	v := r.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// sleep waits for the given duration, or returns early with an error if
+// the context is canceled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "context canceled while waiting to retry")
+	case <-timer.C:
+		return nil
+	}
+}
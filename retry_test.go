@@ -0,0 +1,212 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRetryPolicy returns a RetryPolicy suitable for tests
+func testRetryPolicy() *RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.MinBackoff = time.Millisecond
+	p.MaxBackoff = 2 * time.Millisecond
+	return p
+}
+
+func TestClient_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       *RetryPolicy
+		failures     int
+		status       int
+		retryAfter   string
+		call         func(ctx context.Context, c *Client) error
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{
+			name:     "should retry GET until it succeeds",
+			policy:   testRetryPolicy(),
+			failures: 2,
+			status:   http.StatusBadGateway,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.GetKV2Secret(ctx, "secret", "foo")
+				return err
+			},
+			wantAttempts: 3,
+		},
+		{
+			name:     "should give up after MaxAttempts",
+			policy:   testRetryPolicy(),
+			failures: 5,
+			status:   http.StatusServiceUnavailable,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.GetKV2Secret(ctx, "secret", "foo")
+				return err
+			},
+			wantAttempts: 3,
+			wantErr:      true,
+		},
+		{
+			name:       "should honor Retry-After",
+			policy:     testRetryPolicy(),
+			failures:   1,
+			status:     http.StatusTooManyRequests,
+			retryAfter: "0",
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.ListKV2Secrets(ctx, "secret", "foo")
+				return err
+			},
+			wantAttempts: 2,
+		},
+		{
+			name:     "should not retry POST by default",
+			policy:   testRetryPolicy(),
+			failures: 1,
+			status:   http.StatusBadGateway,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.TransitDecrypt(ctx, "key", []byte("vault:v1:abc"))
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name: "should retry POST when allowed and replay the body",
+			policy: func() *RetryPolicy {
+				p := testRetryPolicy()
+				p.RetryableMethods = append(p.RetryableMethods, http.MethodPost)
+				return p
+			}(),
+			failures: 1,
+			status:   http.StatusBadGateway,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.TransitDecrypt(ctx, "key", []byte("vault:v1:abc"))
+				return err
+			},
+			wantAttempts: 2,
+		},
+		{
+			name:     "should not retry non-retryable status codes",
+			policy:   testRetryPolicy(),
+			failures: 1,
+			status:   http.StatusForbidden,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.GetKV2Secret(ctx, "secret", "foo")
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:     "should not retry without a policy",
+			failures: 1,
+			status:   http.StatusBadGateway,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.GetKV2Secret(ctx, "secret", "foo")
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+
+				if r.Method == http.MethodPost {
+					b, err := io.ReadAll(r.Body)
+					if err != nil || len(b) == 0 {
+						t.Errorf("attempt %d: expected request body to be replayed, got %q (%v)", n, b, err)
+					}
+				}
+
+				if int(n) <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					w.Write([]byte(`{"errors":["try again"]}`)) //nolint:errcheck // Why: test server
+					return
+				}
+
+				w.Write([]byte(`{"data":{"plaintext":""}}`)) //nolint:errcheck // Why: test server
+			}))
+			defer srv.Close()
+
+			c := New(WithAddress(srv.URL), WithRetryPolicy(tt.policy))
+			err := tt.call(context.Background(), c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("call error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := atomic.LoadInt32(&attempts); got != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, got)
+			}
+		})
+	}
+}
+
+func TestClient_RetryHealth(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		var attempts int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(status)
+			w.Write([]byte(`{"initialized":true,"sealed":true,"standby":true}`)) //nolint:errcheck // Why: test server
+		}))
+
+		// the status code of sys/health reports the state of Vault, so
+		// it's returned right away instead of being retried
+		c := New(WithAddress(srv.URL), WithRetryPolicy(testRetryPolicy()))
+		health, err := c.Health(context.Background())
+		srv.Close()
+
+		if err != nil || !health.Standby {
+			t.Errorf("Health(): expected the %d response to be returned, got %+v, %v", status, health, err)
+		}
+
+		if got := atomic.LoadInt32(&attempts); got != 1 {
+			t.Errorf("Health(): expected a %d response not to be retried, got %d attempts", status, got)
+		}
+	}
+}
+
+func TestRetryPolicy_RetryAfterCap(t *testing.T) {
+	p := testRetryPolicy()
+	r := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3600"}}}
+
+	wait, ok := p.shouldRetry(context.Background(), http.MethodGet, "secret/data/foo", 1, r, nil)
+	if !ok {
+		t.Error("shouldRetry(): expected a 429 to be retried")
+		return
+	}
+
+	if wait != p.MaxBackoff {
+		t.Errorf("shouldRetry(): expected Retry-After to be capped at %s, got %s", p.MaxBackoff, wait)
+	}
+
+	r.Header.Set("Retry-After", "0")
+	if wait, _ := p.shouldRetry(context.Background(), http.MethodGet, "secret/data/foo", 1, r, nil); wait != 0 {
+		t.Errorf("shouldRetry(): expected a shorter Retry-After to be honored, got %s", wait)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		got := p.backoff(attempt)
+		if got < p.MinBackoff/2 || got > p.MaxBackoff {
+			t.Errorf("backoff(%d) = %s, expected to be within [%s, %s]", attempt, got, p.MinBackoff/2, p.MaxBackoff)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores functions to interact with basic /sys/wrapping endpoints
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getoutreach/gobox/pkg/cfg"
+	"github.com/pkg/errors"
+)
+
+// WrapInfo is returned by Vault in place of a response when response
+// wrapping was requested, docs:
+// https://developer.hashicorp.com/vault/docs/concepts/response-wrapping
+type WrapInfo struct {
+	// Token is the wrapping token, it can be used (once) to unwrap the response
+	Token cfg.SecretData `json:"token"`
+
+	// Accessor is the accessor of the wrapping token
+	Accessor string `json:"accessor"`
+
+	// TTL is how long the wrapping token is valid for in seconds
+	TTL int `json:"ttl"`
+
+	// CreationTime is when the wrapping token was created
+	CreationTime time.Time `json:"creation_time"`
+
+	// CreationPath is the path of the request that was wrapped
+	CreationPath string `json:"creation_path"`
+
+	// WrappedAccessor is the accessor of the wrapped token, if the wrapped
+	// response was a token
+	WrappedAccessor string `json:"wrapped_accessor"`
+}
+
+// wrapTTLHeader is the header that requests a response to be wrapped
+const wrapTTLHeader = "X-Vault-Wrap-TTL"
+
+// wrapState tracks response wrapping for a client created by Wrap
+type wrapState struct {
+	ttl time.Duration
+
+	mu   sync.Mutex
+	info *WrapInfo
+}
+
+// header returns the value of the X-Vault-Wrap-TTL header
+func (w *wrapState) header() string {
+	return wrapTTLValue(w.ttl)
+}
+
+// wrapTTLValue formats ttl as the value of the X-Vault-Wrap-TTL header
+func wrapTTLValue(ttl time.Duration) string {
+	return fmt.Sprintf("%ds", int(ttl.Seconds()))
+}
+
+// defaultWrapTTL returns the value of the X-Vault-Wrap-TTL header set by
+// WithWrapTTL for a request to endpoint, or an empty string if the request
+// shouldn't be wrapped by default. Requests made through Wrap use its TTL
+// instead.
+func (c *Client) defaultWrapTTL(endpoint string) string {
+	if c.wrap != nil || c.opts.WrapTTL < time.Second || strings.HasPrefix(endpoint, "sys/wrapping/") {
+		return ""
+	}
+	return wrapTTLValue(c.opts.WrapTTL)
+}
+
+// record reads the wrap_info out of a wrapped response body
+func (w *wrapState) record(body []byte) error {
+	var resp struct {
+		WrapInfo *WrapInfo `json:"wrap_info"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return errors.Wrap(err, "failed to decode wrapped response")
+	}
+
+	if resp.WrapInfo == nil {
+		return errors.New("expected a wrapped response but Vault didn't return wrap_info")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.info = resp.WrapInfo
+	return nil
+}
+
+// Wrap calls fn with a copy of the client whose responses are wrapped with
+// the provided TTL, and returns the WrapInfo of the wrapped response instead
+// of the normal payload. fn should make exactly one request, e.g.
+//
+//	wrapInfo, err := c.Wrap(5*time.Minute, func(wc *vault_client.Client) error {
+//		_, err := wc.CreateApproleSecretID(ctx, "my-role")
+//		return err
+//	})
+func (c *Client) Wrap(ttl time.Duration, fn func(wc *Client) error) (*WrapInfo, error) {
+	if ttl < time.Second {
+		return nil, errors.Errorf("wrap ttl must be at least 1s, got %s", ttl)
+	}
+
+	wc := *c
+	wc.wrap = &wrapState{ttl: ttl}
+	if err := fn(&wc); err != nil {
+		return nil, err
+	}
+
+	wc.wrap.mu.Lock()
+	defer wc.wrap.mu.Unlock()
+	if wc.wrap.info == nil {
+		return nil, errors.New("no request was made to be wrapped")
+	}
+
+	return wc.wrap.info, nil
+}
+
+// Unwrap unwraps the response wrapped by the wrapping token, authenticating
+// with the client's own token, and decodes the original response into resp.
+func (c *Client) Unwrap(ctx context.Context, token cfg.SecretData, resp interface{}) error {
+	return c.doRequest(ctx, http.MethodPost, "sys/wrapping/unwrap", map[string]string{
+		"token": string(token),
+	}, resp)
+}
+
+// UnwrapWithWrappingToken unwraps the response wrapped by the wrapping token,
+// using the wrapping token itself as the credential, and decodes the original
+// response into resp. This doesn't require the client to be authenticated.
+func (c *Client) UnwrapWithWrappingToken(ctx context.Context, token cfg.SecretData, resp interface{}) error {
+	wc := New(withInheritedOptions(c.opts), WithTokenAuth(token))
+	return wc.doRequest(ctx, http.MethodPost, "sys/wrapping/unwrap", nil, resp)
+}
+
+// LookupWrappingResponse is the response returned by LookupWrapping, docs:
+// https://developer.hashicorp.com/vault/api-docs/system/wrapping-lookup#sample-response
+type LookupWrappingResponse struct {
+	// CreationPath is the path of the request that was wrapped
+	CreationPath string `json:"creation_path"`
+
+	// CreationTime is when the wrapping token was created
+	CreationTime time.Time `json:"creation_time"`
+
+	// CreationTTL is the TTL the wrapping token was created with in seconds
+	CreationTTL int `json:"creation_ttl"`
+}
+
+// LookupWrapping returns information about a wrapping token without
+// unwrapping it.
+func (c *Client) LookupWrapping(ctx context.Context, token cfg.SecretData) (*LookupWrappingResponse, error) {
+	var resp struct {
+		Data LookupWrappingResponse `json:"data"`
+	}
+	err := c.doRequest(ctx, http.MethodPost, "sys/wrapping/lookup", map[string]string{
+		"token": string(token),
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp.Data, nil
+}
+
+// Rewrap rewraps the response wrapped by the wrapping token into a new
+// wrapping token, invalidating the old one.
+func (c *Client) Rewrap(ctx context.Context, token cfg.SecretData) (*WrapInfo, error) {
+	var resp struct {
+		WrapInfo *WrapInfo `json:"wrap_info"`
+	}
+	err := c.doRequest(ctx, http.MethodPost, "sys/wrapping/rewrap", map[string]string{
+		"token": string(token),
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.WrapInfo == nil {
+		return nil, errors.New("vault didn't return wrap_info")
+	}
+	return resp.WrapInfo, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getoutreach/vault-client/pkg/vaulttest"
+)
+
+func TestClient_Wrapping(t *testing.T) {
+	vc, cleanupFn := createTestVaultServer(t, false)
+	defer cleanupFn()
+
+	ctx := context.Background()
+
+	if err := vc.CreateAuthMethod(ctx, &CreateAuthMethodOptions{Type: "approle"}); err != nil {
+		t.Errorf("Failed to create pre-req auth method: CreateAuthMethod() = %v", err)
+		return
+	}
+
+	if err := vc.CreateApprole(ctx, &CreateApproleOptions{Name: t.Name()}); err != nil {
+		t.Errorf("Failed to create pre-req approle: CreateApprole() = %v", err)
+		return
+	}
+
+	wrapSecretID := func(wc *Client) error {
+		_, err := wc.CreateApproleSecretID(ctx, t.Name())
+		return err
+	}
+
+	wrapInfo, err := vc.Wrap(time.Minute, wrapSecretID)
+	if err != nil {
+		t.Errorf("Failed to wrap secret-id: Wrap() = %v", err)
+		return
+	}
+
+	if wrapInfo.Token == "" || wrapInfo.TTL != 60 {
+		t.Errorf("Wrap(): expected a wrapping token with a TTL of 60, got %+v", wrapInfo)
+		return
+	}
+
+	lookup, err := vc.LookupWrapping(ctx, wrapInfo.Token)
+	if err != nil {
+		t.Errorf("Failed to lookup wrapping token: LookupWrapping() = %v", err)
+		return
+	}
+
+	if !strings.HasSuffix(lookup.CreationPath, "/secret-id") {
+		t.Errorf("LookupWrapping(): unexpected creation path %q", lookup.CreationPath)
+	}
+
+	rewrapped, err := vc.Rewrap(ctx, wrapInfo.Token)
+	if err != nil {
+		t.Errorf("Failed to rewrap wrapping token: Rewrap() = %v", err)
+		return
+	}
+
+	if rewrapped.Token == wrapInfo.Token {
+		t.Error("Rewrap(): expected a new wrapping token")
+	}
+
+	// the wrapping token has been invalidated by the rewrap
+	var resp struct {
+		Data CreateApproleSecretIDResponse `json:"data"`
+	}
+	if err := vc.Unwrap(ctx, wrapInfo.Token, &resp); err == nil {
+		t.Error("Unwrap(): expected unwrapping an invalidated token to fail")
+	}
+
+	unauthenticated := New(WithAddress(vc.opts.Host))
+	if err := unauthenticated.UnwrapWithWrappingToken(ctx, rewrapped.Token, &resp); err != nil {
+		t.Errorf("Failed to unwrap secret-id: UnwrapWithWrappingToken() = %v", err)
+		return
+	}
+
+	if resp.Data.SecretID == "" {
+		t.Error("UnwrapWithWrappingToken(): expected the unwrapped secret-id to have a value")
+	}
+
+	wrapInfo, err = vc.Wrap(time.Minute, wrapSecretID)
+	if err != nil {
+		t.Errorf("Failed to wrap secret-id: Wrap() = %v", err)
+		return
+	}
+
+	resp.Data.SecretID = ""
+	if err := vc.Unwrap(ctx, wrapInfo.Token, &resp); err != nil {
+		t.Errorf("Failed to unwrap secret-id: Unwrap() = %v", err)
+		return
+	}
+
+	if resp.Data.SecretID == "" {
+		t.Error("Unwrap(): expected the unwrapped secret-id to have a value")
+	}
+}
+
+func TestClient_Wrap_NoRequest(t *testing.T) {
+	c := New()
+	if _, err := c.Wrap(time.Minute, func(*Client) error { return nil }); err == nil {
+		t.Error("Wrap(): expected an error when no request was made")
+	}
+}
+
+func TestClient_WithWrapTTL(t *testing.T) {
+	host, token, cleanupFn := vaulttest.NewInMemoryServer(t, false)
+	defer cleanupFn()
+	vc := New(WithAddress(host), WithTokenAuth(token))
+
+	ctx := context.Background()
+
+	if err := vc.CreateAuthMethod(ctx, &CreateAuthMethodOptions{Type: "approle"}); err != nil {
+		t.Errorf("Failed to create pre-req auth method: CreateAuthMethod() = %v", err)
+		return
+	}
+
+	if err := vc.CreateApprole(ctx, &CreateApproleOptions{Name: t.Name()}); err != nil {
+		t.Errorf("Failed to create pre-req approle: CreateApprole() = %v", err)
+		return
+	}
+
+	secretIDPath := "auth/approle/role/" + t.Name() + "/secret-id"
+	wc := New(WithAddress(host), WithTokenAuth(token), WithWrapTTL(2*time.Minute))
+
+	// responses are wrapped by default
+	sec, err := wc.Write(ctx, secretIDPath, nil)
+	if err != nil {
+		t.Errorf("Failed to create secret-id: Write() = %v", err)
+		return
+	}
+
+	if sec.WrapInfo == nil || sec.WrapInfo.TTL != 120 || sec.Data != nil {
+		t.Errorf("Write(): expected a response wrapped with a TTL of 120, got %+v", sec)
+		return
+	}
+
+	// the sys/wrapping endpoints aren't wrapped, so the response can be
+	// unwrapped with the same client
+	var resp struct {
+		Data CreateApproleSecretIDResponse `json:"data"`
+	}
+	if err := wc.Unwrap(ctx, sec.WrapInfo.Token, &resp); err != nil || resp.Data.SecretID == "" {
+		t.Errorf("Failed to unwrap secret-id: Unwrap() = %v, %+v", err, resp)
+		return
+	}
+
+	// typed responses can't hold the wrap_info, so it's returned in an
+	// error rather than dropped
+	_, err = wc.CreateApproleSecretID(ctx, t.Name())
+	wrapInfo := WrapInfoFromError(err)
+	if wrapInfo == nil || wrapInfo.Token == "" {
+		t.Errorf("CreateApproleSecretID(): expected a *WrappedResponseError, got %v", err)
+		return
+	}
+
+	resp.Data.SecretID = ""
+	if err := wc.Unwrap(ctx, wrapInfo.Token, &resp); err != nil || resp.Data.SecretID == "" {
+		t.Errorf("Failed to unwrap secret-id: Unwrap() = %v, %+v", err, resp)
+		return
+	}
+
+	// Wrap takes precedence over the client's wrap TTL
+	wrapInfo, err = wc.Wrap(time.Minute, func(c *Client) error {
+		_, err := c.Write(ctx, secretIDPath, nil)
+		return err
+	})
+	if err != nil || wrapInfo.TTL != 60 {
+		t.Errorf("Wrap(): expected a TTL of 60, got %+v, %v", wrapInfo, err)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+//
+// Description: Stores the TLS configuration used to talk to Vault
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// TLSConfig configures how a Client verifies Vault's certificate and which
+// client certificate, if any, it presents. The fields mirror the TLS
+// environment variables used by the Vault CLI.
+type TLSConfig struct {
+	// CACert is the path to a PEM encoded CA certificate file used to
+	// verify Vault's certificate (VAULT_CACERT)
+	CACert string
+
+	// CAPath is the path to a directory of PEM encoded CA certificate
+	// files used to verify Vault's certificate (VAULT_CAPATH)
+	CAPath string
+
+	// CACertBytes are PEM encoded CA certificates used to verify Vault's
+	// certificate
+	CACertBytes []byte
+
+	// ClientCert is the path to a PEM encoded client certificate presented
+	// to Vault, e.g. for mTLS or cert auth (VAULT_CLIENT_CERT)
+	ClientCert string
+
+	// ClientKey is the path to the PEM encoded private key of ClientCert
+	// (VAULT_CLIENT_KEY)
+	ClientKey string
+
+	// ServerName is the name used for SNI and to verify Vault's certificate
+	// (VAULT_TLS_SERVER_NAME)
+	ServerName string
+
+	// Insecure disables verification of Vault's certificate, this should
+	// only ever be used for local development (VAULT_SKIP_VERIFY)
+	Insecure bool
+}
+
+// clone returns a copy of the TLSConfig, or an empty one if t is nil
+func (t *TLSConfig) clone() *TLSConfig {
+	if t == nil {
+		return &TLSConfig{}
+	}
+
+	cp := *t
+	return &cp
+}
+
+// tlsConfig converts the TLSConfig into a *tls.Config, loading all of the
+// referenced files.
+func (t *TLSConfig) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.Insecure, //nolint:gosec // Why: only when explicitly requested
+	}
+
+	if t.CACert != "" || t.CAPath != "" || len(t.CACertBytes) != 0 {
+		pool := x509.NewCertPool()
+
+		if t.CACert != "" {
+			if err := appendCertFile(pool, t.CACert); err != nil {
+				return nil, err
+			}
+		}
+
+		if t.CAPath != "" {
+			if err := appendCertDir(pool, t.CAPath); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(t.CACertBytes) != 0 && !pool.AppendCertsFromPEM(t.CACertBytes) {
+			return nil, errors.New("failed to parse provided CA certificate bytes")
+		}
+
+		conf.RootCAs = pool
+	}
+
+	if t.ClientCert != "" || t.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+// appendCertFile adds the PEM encoded certificates in file to the pool
+func appendCertFile(pool *x509.CertPool, file string) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read CA certificate '%s'", file)
+	}
+
+	if !pool.AppendCertsFromPEM(b) {
+		return errors.Errorf("failed to parse CA certificate '%s'", file)
+	}
+	return nil
+}
+
+// appendCertDir adds the PEM encoded certificates of every file in dir to
+// the pool. Files that don't contain certificates are skipped.
+func appendCertDir(pool *x509.CertPool, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read CA path '%s'", dir)
+	}
+
+	var found bool
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return errors.Wrapf(err, "failed to read CA certificate '%s'", entry.Name())
+		}
+
+		if pool.AppendCertsFromPEM(b) {
+			found = true
+		}
+	}
+
+	if !found {
+		return errors.Errorf("no CA certificates found in '%s'", dir)
+	}
+	return nil
+}
+
+// newBaseTransport returns the http.RoundTripper used to talk to Vault,
+// configured with the provided TLS settings. If they can't be loaded a
+// http.RoundTripper is returned that fails every request with the error.
+func newBaseTransport(t *TLSConfig) http.RoundTripper {
+	if t == nil {
+		return http.DefaultTransport
+	}
+
+	conf, err := t.tlsConfig()
+	if err != nil {
+		return errTransport{errors.Wrap(err, "failed to configure TLS")}
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = conf
+	return tr
+}
+
+// errTransport is a http.RoundTripper that always returns an error, it's
+// used to surface configuration errors from New() on every request.
+type errTransport struct {
+	err error
+}
+
+// RoundTrip implements http.RoundTripper
+func (t errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
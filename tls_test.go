@@ -0,0 +1,274 @@
+// Copyright 2021 Outreach Corporation. All Rights Reserved.
+package vault_client //nolint:revive // Why: We're using - in the name
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testCert is a certificate, and its key, generated for tests
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+
+	// CertFile and KeyFile are the PEM encoded certificate and key on disk
+	CertFile string
+	KeyFile  string
+}
+
+// PEM returns the PEM encoded certificate
+func (c *testCert) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+}
+
+// TLSCertificate returns the certificate as a tls.Certificate
+func (c *testCert) TLSCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA if
+// parent is nil, and writes it to dir.
+func newTestCert(t *testing.T, dir, name string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+
+	if err := os.WriteFile(tc.CertFile, tc.PEM(), 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(tc.KeyFile, keyPEM, 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	return tc
+}
+
+// newTestTLSServer starts a TLS server, signed by ca, that requires a client
+// certificate signed by ca.
+func newTestTLSServer(t *testing.T, ca *testCert, handler http.Handler) *httptest.Server {
+	t.Helper()
+
+	serverCert := newTestCert(t, t.TempDir(), "vault.test", ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{serverCert.TLSCertificate()},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	srv.StartTLS()
+	return srv
+}
+
+func TestClient_TLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil)
+	client := newTestCert(t, dir, "client", ca)
+
+	var loginCerts int
+	srv := newTestTLSServer(t, ca, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/approle/login" && len(r.TLS.PeerCertificates) != 0 {
+			loginCerts++
+		}
+		w.Write([]byte(`{"auth":{"client_token":"token","lease_duration":3600},"data":{}}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	caDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(caDir, "ca.pem"), ca.PEM(), 0o600); err != nil {
+		t.Fatalf("failed to write CA: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Opts
+		wantErr bool
+	}{
+		{
+			name: "should connect with CA file and client certificate",
+			opts: []Opts{WithCACert(ca.CertFile), WithClientCert(client.CertFile, client.KeyFile)},
+		},
+		{
+			name: "should connect with CA directory",
+			opts: []Opts{WithCAPath(caDir), WithClientCert(client.CertFile, client.KeyFile)},
+		},
+		{
+			name: "should connect with CA bytes and server name",
+			opts: []Opts{
+				WithCACertBytes(ca.PEM()), WithTLSServerName("vault.test"),
+				WithClientCert(client.CertFile, client.KeyFile),
+			},
+		},
+		{
+			name: "should connect when skipping verification",
+			opts: []Opts{WithInsecureSkipVerify(true), WithClientCert(client.CertFile, client.KeyFile)},
+		},
+		{
+			name:    "should fail without the CA",
+			opts:    []Opts{WithClientCert(client.CertFile, client.KeyFile)},
+			wantErr: true,
+		},
+		{
+			name:    "should fail without a client certificate",
+			opts:    []Opts{WithCACert(ca.CertFile)},
+			wantErr: true,
+		},
+		{
+			name:    "should fail with a missing CA file",
+			opts:    []Opts{WithCACert(filepath.Join(dir, "missing.pem"))},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(append([]Opts{WithAddress(srv.URL)}, tt.opts...)...)
+			_, err := c.LookupCurrentToken(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LookupCurrentToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("should use the same TLS settings for auth method clients", func(t *testing.T) {
+		c := New(WithAddress(srv.URL), WithCACert(ca.CertFile), WithClientCert(client.CertFile, client.KeyFile),
+			WithApproleAuth("role-id", "secret-id"))
+		if _, err := c.LookupCurrentToken(context.Background()); err != nil {
+			t.Errorf("LookupCurrentToken() = %v", err)
+			return
+		}
+
+		if loginCerts != 1 {
+			t.Errorf("expected approle login to present a client certificate")
+		}
+	})
+}
+
+func TestWithEnv_TLS(t *testing.T) {
+	t.Setenv("VAULT_CACERT", "/ca.pem")
+	t.Setenv("VAULT_CAPATH", "/certs")
+	t.Setenv("VAULT_CLIENT_CERT", "/client.pem")
+	t.Setenv("VAULT_CLIENT_KEY", "/client-key.pem")
+	t.Setenv("VAULT_TLS_SERVER_NAME", "vault.test")
+	t.Setenv("VAULT_SKIP_VERIFY", "1")
+
+	opts := &Options{}
+	WithEnv(opts)
+
+	want := &TLSConfig{
+		CACert:     "/ca.pem",
+		CAPath:     "/certs",
+		ClientCert: "/client.pem",
+		ClientKey:  "/client-key.pem",
+		ServerName: "vault.test",
+		Insecure:   true,
+	}
+	if diff := cmp.Diff(want, opts.TLS); diff != "" {
+		t.Errorf("WithEnv(): %s", diff)
+	}
+}
+
+func TestWithEnv_SkipVerify(t *testing.T) {
+	tests := map[string]struct {
+		value    string
+		insecure bool
+		err      bool
+	}{
+		"empty":   {value: ""},
+		"false":   {value: "false"},
+		"true":    {value: "true", insecure: true},
+		"1":       {value: "1", insecure: true},
+		"no":      {value: "no", err: true},
+		"garbage": {value: "yes please", err: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("VAULT_SKIP_VERIFY", test.value)
+
+			opts := &Options{}
+			WithEnv(opts)
+
+			if insecure := opts.TLS != nil && opts.TLS.Insecure; insecure != test.insecure {
+				t.Errorf("WithEnv(): expected Insecure to be %v, got %v", test.insecure, insecure)
+			}
+
+			if (opts.envErr != nil) != test.err {
+				t.Errorf("WithEnv(): expected an error %v, got %v", test.err, opts.envErr)
+			}
+		})
+	}
+
+	// requests fail rather than being sent without verification
+	t.Setenv("VAULT_SKIP_VERIFY", "no")
+	c := New(WithEnv, WithAddress("https://127.0.0.1:0"))
+	if _, err := c.Health(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid VAULT_SKIP_VERIFY") {
+		t.Errorf("Health(): expected an error for an invalid VAULT_SKIP_VERIFY, got %v", err)
+	}
+}
@@ -7,10 +7,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json" // Client is a Vault client
-	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/getoutreach/gobox/pkg/log"
 	"github.com/getoutreach/gobox/pkg/trace"
@@ -22,6 +24,10 @@ type Client struct {
 	opts *Options
 
 	hc *http.Client
+
+	// wrap is set on clients created by Wrap, when set responses
+	// are wrapped and their wrap_info is recorded.
+	wrap *wrapState
 }
 
 // New creates a new Vault client. By default it is non-functional. Most likely
@@ -34,55 +40,132 @@ func New(optFns ...Opts) *Client {
 		optFn(opts)
 	}
 
+	if opts.tr == nil {
+		opts.tr = newBaseTransport(opts.TLS)
+		if opts.envErr != nil {
+			opts.tr = errTransport{opts.envErr}
+		}
+	}
+
 	hc := (*http.DefaultClient)
+	hc.Transport = opts.tr
 	if opts.am != nil {
 		// pass the options we created earlier to the AuthMethod
 		// so it can create it's own client.
 		opts.am.Options(opts)
 
-		hc.Transport = NewTransport(http.DefaultTransport, opts.am)
+		hc.Transport = newTransport(opts)
 	}
 
-	return &Client{opts, &hc}
+	return &Client{opts: opts, hc: &hc}
 }
 
-// ErrorResponse is returned when an error occurs
-type ErrorResponse struct {
-	// Errors is a list of errors that were encountered when Vault tried
-	// to process this request.
-	Errors []string `json:"errors"`
+// WithNamespace returns a copy of the client that makes requests in the
+// provided namespace, relative to the client's current namespace. The
+// returned client shares the underlying transport, and thus authentication,
+// with c.
+func (c *Client) WithNamespace(namespace string) *Client {
+	opts := *c.opts
+	opts.Namespace = strings.Trim(path.Join(c.opts.Namespace, namespace), "/")
+	return &Client{opts: &opts, hc: c.hc, wrap: c.wrap}
 }
 
-// doRequest sends a request
+// Close releases the resources held by the client: it stops the client's
+// LifetimeWatchers, revokes the token obtained by its auth method and closes
+// idle connections. Tokens provided to the client, e.g. with WithTokenAuth,
+// are only revoked when WithRevokeStaticTokenOnClose is used. Clients sharing
+// the client's authentication, e.g. those created by WithNamespace, can't be
+// used after Close either.
 //
-//nolint:funlen // Why: not that important to break out
+//	c := vault_client.New(vault_client.WithEnv)
+//	defer c.Close(ctx)
+func (c *Client) Close(ctx context.Context) error {
+	defer c.hc.CloseIdleConnections()
+
+	t, ok := c.hc.Transport.(*transport)
+	if !ok {
+		return nil
+	}
+	return t.close(ctx, c.opts.RevokeStaticTokenOnClose)
+}
+
+// doRequest sends a request
 func (c *Client) doRequest(ctx context.Context, method, endpoint string, body, resp interface{}) error {
+	return c.doRequestWithHeader(ctx, method, endpoint, nil, body, resp)
+}
+
+// doRequestWithHeader sends a request with additional headers, through
+// the client's middleware.
+func (c *Client) doRequestWithHeader(ctx context.Context, method, endpoint string, header http.Header,
+	body, resp interface{}) error {
+	return c.sendRequest(ctx, method, endpoint, nil, header, body, resp)
+}
+
+// doRequestWithQuery sends a request with query parameters. The query is
+// only sent to Vault, it's kept out of traces, the RequestInfo passed to
+// middleware and errors, since it may contain secrets, e.g. OIDC codes.
+func (c *Client) doRequestWithQuery(ctx context.Context, method, endpoint string, query url.Values, body, resp interface{}) error {
+	return c.sendRequest(ctx, method, endpoint, query, nil, body, resp)
+}
+
+// sendRequest sends a request with optional query parameters and headers,
+// through the client's middleware.
+func (c *Client) sendRequest(ctx context.Context, method, endpoint string, query url.Values, header http.Header,
+	body, resp interface{}) error {
 	uri := c.opts.Host + path.Join("/v1/", endpoint)
 
 	ctx = trace.StartCall(ctx, "vault.request", log.F{"vault.uri": uri, "vault.method": method})
 	defer trace.EndCall(ctx)
 
-	var bodyReader io.Reader
+	// the query is added after tracing, so it's never recorded
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+
+	var b []byte
 	if body != nil {
-		b, err := json.Marshal(body)
+		var err error
+		b, err = json.Marshal(body)
 		if err != nil {
 			return errors.Wrap(err, "failed to serialize request into json")
 		}
+	}
 
-		bodyReader = bytes.NewReader(b)
+	if header == nil {
+		header = http.Header{}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, uri, bodyReader)
-	if err != nil {
-		return errors.Wrap(err, "failed to create request")
+	if ttl := c.defaultWrapTTL(endpoint); ttl != "" && header.Get(wrapTTLHeader) == "" {
+		header.Set(wrapTTLHeader, ttl)
 	}
 
-	r, err := c.hc.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "failed to make request")
+	req := &RequestInfo{
+		Method:   method,
+		Endpoint: endpoint,
+		Header:   header,
+		Body:     c.middlewareBody(b),
 	}
-	defer r.Body.Close()
 
+	return c.wrapMiddleware(func(ctx context.Context, req *RequestInfo) error {
+		start := time.Now()
+		defer func() { req.Latency = time.Since(start) }()
+
+		r, err := c.send(ctx, method, endpoint, uri, req.Header, b)
+		if err != nil {
+			return err
+		}
+		defer r.Body.Close()
+
+		req.StatusCode = r.StatusCode
+		return c.decodeResponse(r, method, endpoint, resp)
+	})(ctx, req)
+}
+
+// decodeResponse decodes a response from Vault into resp, or returns a
+// *ResponseError if the response was an error.
+//
+//nolint:funlen // Why: not that important to break out
+func (c *Client) decodeResponse(r *http.Response, method, endpoint string, resp interface{}) error {
 	// Useful debugging code
 	// buf := &bytes.Buffer{}
 	// r.Body = io.NopCloser(io.TeeReader(r.Body, buf))
@@ -98,14 +181,51 @@ func (c *Client) doRequest(ctx context.Context, method, endpoint string, body, r
 			return errors.Wrap(err, "failed to read response")
 		}
 
+		respErr := &ResponseError{StatusCode: r.StatusCode, Method: method, Endpoint: endpoint}
+
 		var errResp ErrorResponse
-		if err := json.Unmarshal(b, &errResp); err == nil && len(errResp.Errors) >= 1 {
-			return fmt.Errorf("%v", errResp.Errors)
+		if err := json.Unmarshal(b, &errResp); err == nil && errResp.Errors != nil {
+			respErr.Errors = errResp.Errors
+			respErr.Warnings = errResp.Warnings
+			return respErr
+		}
+
+		// some endpoints, e.g. sys/health, return a non-error response body with
+		// an error status code, so optimistically try to parse it as the response.
+		if resp != nil && json.Unmarshal(b, resp) == nil {
+			return nil
+		}
+
+		if msg := strings.TrimSpace(string(b)); msg != "" {
+			respErr.Errors = []string{msg}
+		}
+		return respErr
+	}
+
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if c.wrap != nil {
+		// the response was wrapped, so the only payload is the wrap_info
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return errors.Wrap(err, "failed to read response")
+		}
+
+		if err := c.wrap.record(b); err != nil {
+			return err
+		}
+
+		if resp != nil {
+			return errors.Wrap(json.Unmarshal(b, resp), "failed to decode response")
 		}
+		return nil
+	}
 
-		// set the body back to the original contents
-		// so that we can optimistically try to parse the non-errors response as JSON again
-		r.Body = io.NopCloser(bytes.NewReader(b))
+	if r.Request != nil && r.Request.Header.Get(wrapTTLHeader) != "" {
+		// wrapping was requested by default, see WithWrapTTL
+		return c.decodeDefaultWrappedResponse(r, method, endpoint, resp)
 	}
 
 	if resp != nil {
@@ -115,3 +235,105 @@ func (c *Client) doRequest(ctx context.Context, method, endpoint string, body, r
 
 	return nil
 }
+
+// decodeDefaultWrappedResponse decodes a response that may have been wrapped
+// because of WithWrapTTL. Only a Secret can hold the wrap_info, for any
+// other resp a *WrappedResponseError is returned, so the wrapping token
+// isn't silently dropped.
+func (c *Client) decodeDefaultWrappedResponse(r *http.Response, method, endpoint string, resp interface{}) error {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response")
+	}
+
+	var wrapped struct {
+		WrapInfo *WrapInfo `json:"wrap_info"`
+	}
+	if err := json.Unmarshal(b, &wrapped); err != nil {
+		return errors.Wrap(err, "failed to decode response")
+	}
+
+	if wrapped.WrapInfo != nil {
+		switch resp.(type) {
+		case *Secret, **Secret:
+		default:
+			return &WrappedResponseError{Method: method, Endpoint: endpoint, WrapInfo: wrapped.WrapInfo}
+		}
+	}
+
+	if resp != nil {
+		return errors.Wrap(json.Unmarshal(b, resp), "failed to decode response")
+	}
+	return nil
+}
+
+// redactURLError removes the query from the URL of a *url.Error, which is
+// included in its message, since the query may contain secrets, see
+// doRequestWithQuery. Other errors are returned as is.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	if i := strings.IndexByte(urlErr.URL, '?'); i >= 0 {
+		urlErr.URL = urlErr.URL[:i]
+	}
+	return err
+}
+
+// send sends a request for endpoint to Vault, at uri, retrying it according
+// to the client's RetryPolicy. The body is replayed on every attempt.
+func (c *Client) send(ctx context.Context, method, endpoint, uri string, header http.Header,
+	body []byte) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, uri, bodyReader)
+		if err != nil {
+			return nil, errors.Wrap(redactURLError(err), "failed to create request")
+		}
+
+		if c.opts.Namespace != "" {
+			req.Header.Set("X-Vault-Namespace", c.opts.Namespace)
+		}
+
+		// headers provided by the caller, or middleware, take precedence
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		if c.wrap != nil {
+			req.Header.Set(wrapTTLHeader, c.wrap.header())
+		}
+
+		r, err := c.hc.Do(req)
+		err = redactURLError(err)
+		wait, retry := c.opts.RetryPolicy.shouldRetry(ctx, method, endpoint, attempt, r, err)
+		if !retry {
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make request")
+			}
+			return r, nil
+		}
+
+		retryInfo := log.F{"vault.attempts": attempt + 1, "vault.retry.wait": wait.String()}
+		if err != nil {
+			retryInfo["vault.retry.error"] = err.Error()
+		} else {
+			retryInfo["vault.retry.status"] = r.StatusCode
+
+			// drain the body so the connection can be reused
+			io.Copy(io.Discard, r.Body) //nolint:errcheck // Why: best effort
+			r.Body.Close()
+		}
+		trace.AddInfo(ctx, retryInfo)
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
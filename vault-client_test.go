@@ -3,9 +3,12 @@ package vault_client //nolint:revive // Why: We're using - in the name
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/getoutreach/vault-client/pkg/vaulttest"
+	"github.com/google/go-cmp/cmp"
 )
 
 // createTestVaultSever creates a Vault server and returns a
@@ -26,3 +29,106 @@ func TestClient_Failure(t *testing.T) {
 		t.Error("expected invalid token lookup to fail")
 	}
 }
+
+func TestClient_Namespace(t *testing.T) {
+	var gotNamespaces []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotNamespaces = append(gotNamespaces, r.Header.Get("X-Vault-Namespace"))
+		w.Write([]byte(`{"data":{}}`)) //nolint:errcheck // Why: test server
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(WithAddress(srv.URL), WithNamespace("/parent/"), WithTokenAuth("token"))
+	child := c.WithNamespace("child")
+
+	if _, err := c.LookupCurrentToken(ctx); err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	if _, err := child.LookupCurrentToken(ctx); err != nil {
+		t.Errorf("child LookupCurrentToken() = %v", err)
+		return
+	}
+
+	if diff := cmp.Diff([]string{"parent", "parent/child"}, gotNamespaces); diff != "" {
+		t.Errorf("X-Vault-Namespace: %s", diff)
+	}
+
+	if c.hc != child.hc {
+		t.Error("WithNamespace(): expected child client to share the parent's http.Client")
+	}
+
+	if c.opts.Namespace != "parent" {
+		t.Errorf("WithNamespace(): expected parent namespace to be unchanged, got %q", c.opts.Namespace)
+	}
+}
+
+func TestClient_Close(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeApproleVault(t)
+
+	c := New(WithAddress(f.URL), WithApproleAuth("role-id", "secret-id"))
+	if _, err := c.LookupCurrentToken(ctx); err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	w, err := c.WatchTokenLifetime(ctx)
+	if err != nil {
+		t.Errorf("WatchTokenLifetime() = %v", err)
+		return
+	}
+
+	if err := c.Close(ctx); err != nil {
+		t.Errorf("Close() = %v", err)
+		return
+	}
+
+	if !f.isRevoked("token-1") {
+		t.Error("Close(): expected the token obtained by logging in to be revoked")
+	}
+
+	select {
+	case <-w.done:
+	default:
+		t.Error("Close(): expected the LifetimeWatcher to be stopped")
+	}
+
+	if _, err := c.LookupCurrentToken(ctx); err == nil {
+		t.Error("LookupCurrentToken(): expected an error for a closed client")
+	}
+
+	if _, err := c.WatchTokenLifetime(ctx); err == nil {
+		t.Error("WatchTokenLifetime(): expected an error for a closed client")
+	}
+
+	if f.loginCount() != 1 {
+		t.Errorf("expected no logins after Close(), got %d logins", f.loginCount())
+	}
+
+	if err := c.Close(ctx); err != nil {
+		t.Errorf("Close(): expected closing twice to succeed, got %v", err)
+	}
+
+	// static tokens are only revoked when opted in
+	static := New(WithAddress(f.URL), WithTokenAuth("static"))
+	if err := static.Close(ctx); err != nil || f.isRevoked("static") {
+		t.Errorf("Close(): expected the static token not to be revoked, got %v", err)
+	}
+
+	static = New(WithAddress(f.URL), WithTokenAuth("static"), WithRevokeStaticTokenOnClose())
+	if _, err := static.LookupCurrentToken(ctx); err != nil {
+		t.Errorf("LookupCurrentToken() = %v", err)
+		return
+	}
+
+	if err := static.Close(ctx); err != nil || !f.isRevoked("static") {
+		t.Errorf("Close(): expected the static token to be revoked, got %v", err)
+	}
+
+	if err := New(WithAddress(f.URL)).Close(ctx); err != nil {
+		t.Errorf("Close(): expected a client without an auth method to close, got %v", err)
+	}
+}